@@ -0,0 +1,671 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ---------- headless CLI ----------
+
+// Exit codes of the headless commands.
+const (
+	exitOK      = 0
+	exitFatal   = 1 // the scan could not run or failed part way
+	exitUsage   = 2 // bad flags or arguments
+	exitPartial = 3 // the scan finished but some paths could not be read, or it was interrupted; or verify found mismatches
+)
+
+// cliProgressInterval is how often plain progress lines are printed.
+const cliProgressInterval = 2 * time.Second
+
+const cliUsage = `Usage:
+  spcatalog                 start the interactive form (or, without a terminal,
+                            scan with the settings the form last saved)
+  spcatalog scan [flags]    catalog a folder without the interactive form
+  spcatalog export [flags]  write the files table to CSV, TSV or an XLSX workbook
+  spcatalog report --html FILE
+                            write a self-contained HTML summary of a catalog
+  spcatalog dupes [flags]   list duplicate files as text, CSV or JSON
+  spcatalog verify [flags]  re-hash files and compare them with the catalog
+  spcatalog mismatches [flags]
+                            list files whose content contradicts their extension
+
+Run 'spcatalog <command> -h' for a command's flags.
+
+Exit codes: 0 success, 1 fatal error, 2 usage error,
+            3 finished with unreadable paths or interrupted, or verify
+            found files that no longer match
+`
+
+// stdoutIsTerminal reports whether stdout is an interactive terminal.
+func stdoutIsTerminal() bool {
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// runCLI runs a headless command and returns the process exit code.
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, cliUsage)
+		return exitUsage
+	}
+	switch args[0] {
+	case "scan":
+		return runScanCommand(args[1:], nil, stdout, stderr)
+	case "export":
+		return runExportCommand(args[1:], stdout, stderr)
+	case "report":
+		return runReportCommand(args[1:], stdout, stderr)
+	case "dupes":
+		return runDupesCommand(args[1:], stdout, stderr)
+	case "verify":
+		return runVerifyCommand(args[1:], stdout, stderr)
+	case "mismatches":
+		return runMismatchesCommand(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, cliUsage)
+		return exitOK
+	}
+	fmt.Fprintf(stderr, "spcatalog: unknown command %q\n\n%s", args[0], cliUsage)
+	return exitUsage
+}
+
+// defaultOutputDir is where catalogs go when no output dir is given.
+func defaultOutputDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "spcatalog")
+}
+
+// runSavedScan is what main runs when stdout is not a terminal and no
+// command was given: a scan using the settings the form last saved.
+func runSavedScan(stdout, stderr io.Writer) int {
+	return runScanCommand(nil, loadConfig(), stdout, stderr)
+}
+
+// runScanCommand parses scan flags, with defaults taken from config when it
+// is non-nil, and runs the scan.
+func runScanCommand(args []string, config *appConfig, stdout, stderr io.Writer) int {
+	if config == nil {
+		config = &appConfig{}
+	}
+	defaultOut := config.LastOutputDir
+	if defaultOut == "" {
+		defaultOut = defaultOutputDir()
+	}
+	symlinkDefault := config.LastSymlinks
+	if symlinkDefault == "" {
+		symlinkDefault = linkRecord.String()
+	}
+
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	root := flags.String("root", config.LastRootPath, "folder to catalog (or pass it as the first argument)")
+	out := flags.String("out", defaultOut, "output dir; the catalog is written to <out>/catalog.db")
+	ext := flags.String("ext", config.LastExtFilter, "comma-separated extensions to include, e.g. .pdf,.docx")
+	exclude := flags.String("exclude", config.LastExclude, "comma-separated gitignore-style patterns to skip")
+	size := flags.String("size", config.LastSizeFilter, "size filter, e.g. >10MB or 1MB-1GB")
+	modified := flags.String("modified", config.LastModified, `modified filter, e.g. "older than 3y" or "since 2024-01-01"`)
+	hash := flags.Bool("hash", config.LastHashSetting, "compute checksums")
+	hashAlgos := flags.String("hash-algos", config.HashAlgorithms, "comma-separated checksums to compute with --hash: "+hashAlgorithmNames()+" or all (default sha256)")
+	workers := flags.Int("workers", config.HashWorkers, "hash workers (0 = default)")
+	sniff := flags.Bool("sniff", config.LastSniff, "read each file's first bytes to record its real content type")
+	incremental := flags.Bool("incremental", config.LastIncremental, "skip files whose size and mtime are unchanged")
+	resume := flags.Bool("resume", false, "continue the root's last interrupted scan")
+	symlinks := flags.String("symlinks", symlinkDefault, "symlink policy: record, ignore or follow")
+	quiet := flags.Bool("quiet", false, "print nothing but errors (with --json: no progress events)")
+	jsonOut := flags.Bool("json", false, "write NDJSON events to stdout instead of text")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	usageErr := func(format string, a ...any) int {
+		fmt.Fprintf(stderr, "spcatalog scan: "+format+"\n", a...)
+		return exitUsage
+	}
+	if flags.NArg() > 1 {
+		return usageErr("unexpected arguments: %s", strings.Join(flags.Args()[1:], " "))
+	}
+	if flags.NArg() == 1 {
+		*root = flags.Arg(0)
+	}
+	if strings.TrimSpace(*root) == "" {
+		return usageErr("a root folder is required (--root)")
+	}
+	if _, err := os.Stat(*root); err != nil {
+		return usageErr("root not accessible: %v", err)
+	}
+	if *workers < 0 {
+		return usageErr("--workers must not be negative")
+	}
+	policy, ok := lookupSymlinkPolicy(*symlinks)
+	if !ok {
+		return usageErr("--symlinks must be record, ignore or follow, not %q", *symlinks)
+	}
+	filter, err := parseFileFilter(*size, *modified, time.Now())
+	if err != nil {
+		return usageErr("%v", err)
+	}
+	algos, err := parseHashAlgorithms(*hashAlgos)
+	if err != nil {
+		return usageErr("--hash-algos: %v", err)
+	}
+	opts := scanOptions{
+		root:        *root,
+		extFilter:   parseExtSet(strings.TrimSpace(*ext)),
+		exclude:     parseExcludeList(*exclude),
+		filter:      filter,
+		hash:        *hash,
+		hashAlgos:   algos,
+		hashWorkers: *workers,
+		sniff:       *sniff,
+		incremental: *incremental,
+		resume:      *resume,
+		symlinks:    policy,
+	}
+
+	dbPath := filepath.Join(*out, "catalog.db")
+	var events *eventWriter
+	if *jsonOut {
+		events = newEventWriter(stdout)
+	}
+	start := time.Now()
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		fmt.Fprintf(stderr, "spcatalog scan: %v\n", err)
+		if events != nil {
+			events.summary(newScanSummary(dbPath, progressMsg{}, time.Since(start), opts.incremental, err))
+		}
+		return exitFatal
+	}
+
+	// Ctrl+C or SIGTERM stops the walk; the current batch is still committed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var last progressMsg
+	var lastLine time.Time
+	if events != nil {
+		var runID string
+		opts.onStart = func(id, resumedFrom string) {
+			runID = id
+			events.started(id, resumedFrom, dbPath, opts)
+		}
+		opts.onError = func(e scanErrorRecord) { events.scanError(runID, e) }
+	}
+	err = scanAndPersist(ctx, dbPath, opts, func(p progressMsg) {
+		last = p
+		if *quiet || time.Since(lastLine) < cliProgressInterval {
+			return
+		}
+		lastLine = time.Now()
+		if events != nil {
+			events.progress(p, time.Since(start))
+		} else {
+			fmt.Fprintln(stderr, progressLine(p, time.Since(start)))
+		}
+	})
+	summary := newScanSummary(dbPath, last, time.Since(start), opts.incremental, err)
+
+	if events != nil {
+		events.summary(summary)
+		switch {
+		case summary.Status == runFailed:
+			return exitFatal
+		case summary.Status == runCancelled || summary.Errors > 0:
+			return exitPartial
+		}
+		return exitOK
+	}
+
+	if summary.Status == runFailed {
+		fmt.Fprintf(stderr, "spcatalog scan: %v\n", err)
+		return exitFatal
+	}
+	var scanErrors []scanErrorRecord
+	if last.errors > 0 {
+		scanErrors, _ = loadScanErrors(dbPath, last.runID, doneErrorLimit)
+	}
+	if !*quiet {
+		writeScanSummary(stdout, summary)
+	}
+	for _, e := range scanErrors {
+		fmt.Fprintf(stderr, "error: %s %s: %s\n", e.op, e.path, e.err)
+	}
+	if more := last.errors - int64(len(scanErrors)); more > 0 {
+		fmt.Fprintf(stderr, "error: ... and %d more, see scan_errors for run %s\n", more, last.runID)
+	}
+
+	if summary.Status == runCancelled || last.errors > 0 {
+		return exitPartial
+	}
+	return exitOK
+}
+
+// progressLine formats one plain progress line for the headless scan.
+func progressLine(p progressMsg, elapsed time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "files=%d", p.files)
+	if p.estimatedTotal > 0 {
+		total := fmt.Sprintf("%d", p.estimatedTotal)
+		if !p.totalFinal {
+			total = "~" + total
+		}
+		fmt.Fprintf(&b, "/%s", total)
+	}
+	fmt.Fprintf(&b, " folders=%d data=%q", p.folders, formatSize(p.bytes))
+	if elapsed > 0 {
+		fmt.Fprintf(&b, " rate=%s/s", formatSpeed(float64(p.files)/elapsed.Seconds()))
+	}
+	if p.errors > 0 {
+		fmt.Fprintf(&b, " errors=%d", p.errors)
+	}
+	if p.last != "" {
+		fmt.Fprintf(&b, " path=%q", p.last)
+	}
+	return b.String()
+}
+
+// scanSummary is the outcome of a headless scan, printed as text or as the
+// final NDJSON event. It carries what the done screen shows.
+type scanSummary struct {
+	Status         string  `json:"status"`
+	Database       string  `json:"database"`
+	RunID          string  `json:"run_id,omitempty"`
+	ResumedFrom    string  `json:"resumed_from,omitempty"`
+	Files          int64   `json:"files"`
+	Folders        int64   `json:"folders"`
+	Bytes          int64   `json:"bytes"`
+	Incremental    bool    `json:"incremental"`
+	NewFiles       int64   `json:"new_files"`
+	ChangedFiles   int64   `json:"changed_files"`
+	UnchangedFiles int64   `json:"unchanged_files"`
+	DeletedFiles   int64   `json:"deleted_files"`
+	DeletedFolders int64   `json:"deleted_folders"`
+	Errors         int64   `json:"errors"`
+	HashErrors     int64   `json:"hash_errors"`
+	Mismatches     int64   `json:"content_mismatches"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	Error          string  `json:"error,omitempty"`
+}
+
+func newScanSummary(dbPath string, p progressMsg, elapsed time.Duration, incremental bool, err error) scanSummary {
+	s := scanSummary{
+		Status:   runCompleted,
+		Database: dbPath, RunID: p.runID, ResumedFrom: p.resumedFrom,
+		Files: p.files, Folders: p.folders, Bytes: p.bytes,
+		Incremental: incremental,
+		NewFiles:    p.newFiles, ChangedFiles: p.changedFiles, UnchangedFiles: p.unchangedFiles,
+		DeletedFiles: p.deletedFiles, DeletedFolders: p.deletedFolders,
+		Errors: p.errors, HashErrors: p.hashErrors, Mismatches: p.mismatches,
+		ElapsedSeconds: elapsed.Seconds(),
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		s.Status = runCancelled
+	case err != nil:
+		s.Status = runFailed
+		s.Error = err.Error()
+	}
+	return s
+}
+
+// writeScanSummary prints the plain-text equivalent of the done screen.
+func writeScanSummary(w io.Writer, s scanSummary) {
+	line := func(label string, format string, a ...any) {
+		fmt.Fprintf(w, "%-11s%s\n", label+":", fmt.Sprintf(format, a...))
+	}
+	line("status", "%s", s.Status)
+	line("database", "%s", s.Database)
+	runLabel := s.RunID
+	if s.ResumedFrom != "" {
+		runLabel += " (resumed)"
+	}
+	line("run", "%s", runLabel)
+	line("files", "%d", s.Files)
+	line("folders", "%d", s.Folders)
+	line("data", "%s", formatSize(s.Bytes))
+	if s.Incremental {
+		line("new", "%d", s.NewFiles)
+		line("changed", "%d", s.ChangedFiles)
+		line("unchanged", "%d", s.UnchangedFiles)
+	}
+	if s.DeletedFiles > 0 || s.DeletedFolders > 0 {
+		line("deleted", "%d files, %d folders", s.DeletedFiles, s.DeletedFolders)
+	}
+	line("errors", "%d", s.Errors)
+	if s.HashErrors > 0 {
+		line("hash errs", "%d (no checksum stored; rescan with --incremental --hash to retry)", s.HashErrors)
+	}
+	if s.Mismatches > 0 {
+		line("mismatch", "%d (content contradicts the extension; see spcatalog mismatches)", s.Mismatches)
+	}
+	line("elapsed", "%s", (time.Duration(s.ElapsedSeconds * float64(time.Second))).Round(time.Second))
+}
+
+// reportFormatFor picks the text, csv or json format implied by an output
+// file name.
+func reportFormatFor(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv"
+	case ".json":
+		return "json"
+	}
+	return "text"
+}
+
+// openOutput returns where a command writes its --out: stdout for "-", else
+// a new file at path. closeOut must be called with the error from writing;
+// it closes the file and returns the first error, removing the file if there
+// was one so a failed run does not leave a truncated file behind.
+func openOutput(path string, stdout io.Writer) (w io.Writer, closeOut func(error) error, err error) {
+	if path == "-" {
+		return stdout, func(err error) error { return err }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func(err error) error { return closeOutputFile(f, err) }, nil
+}
+
+// runExportCommand writes the files table of a catalog as CSV, TSV or XLSX.
+func runExportCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dbPath := flags.String("db", filepath.Join(defaultOutputDir(), "catalog.db"), "catalog to export")
+	format := flags.String("format", "", "csv, tsv or xlsx (default: from --out's extension, else csv)")
+	columns := flags.String("columns", "", "comma-separated columns (default: "+strings.Join(defaultExportColumns, ",")+")")
+	where := flags.String("where", "", `SQL condition on the exported columns, e.g. "ext = '.pdf' AND size > 1e6"`)
+	folders := flags.Bool("folders", false, "join folders, adding folder_parent_path and folder_mtime_utc")
+	deleted := flags.Bool("include-deleted", false, "include rows flagged as deleted")
+	bom := flags.Bool("bom", true, "start CSV with a UTF-8 byte order mark so Excel reads non-ASCII names")
+	out := flags.String("out", "-", "output file, or - for stdout")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "spcatalog export: unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		return exitUsage
+	}
+	if *format == "" {
+		*format = exportFormatFor(*out)
+	}
+	switch *format {
+	case "csv", "tsv":
+	case "xlsx":
+		if *out == "-" && stdoutIsTerminal() {
+			fmt.Fprintln(stderr, "spcatalog export: not writing a workbook to a terminal; use --out")
+			return exitUsage
+		}
+	default:
+		fmt.Fprintf(stderr, "spcatalog export: --format must be csv, tsv or xlsx, not %q\n", *format)
+		return exitUsage
+	}
+	opts := exportOptions{
+		format:         *format,
+		columns:        parseColumnList(*columns),
+		where:          *where,
+		withFolders:    *folders,
+		includeDeleted: *deleted,
+		bom:            *bom,
+	}
+	if _, _, err := exportQuery(opts); err != nil {
+		fmt.Fprintf(stderr, "spcatalog export: %v\n", err)
+		return exitUsage
+	}
+
+	w, closeOut, err := openOutput(*out, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "spcatalog export: %v\n", err)
+		return exitFatal
+	}
+	n, err := exportCatalog(*dbPath, w, opts)
+	if err = closeOut(err); err != nil {
+		fmt.Fprintf(stderr, "spcatalog export: %v\n", err)
+		return exitFatal
+	}
+	if *out != "-" {
+		fmt.Fprintf(stderr, "exported %d rows to %s\n", n, *out)
+	}
+	return exitOK
+}
+
+// runReportCommand renders the HTML report of a catalog.
+func runReportCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dbPath := flags.String("db", filepath.Join(defaultOutputDir(), "catalog.db"), "catalog to report on")
+	out := flags.String("html", "", "write the HTML report to this file, or - for stdout")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "spcatalog report: unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		return exitUsage
+	}
+	if *out == "" {
+		fmt.Fprintln(stderr, "spcatalog report: --html is required")
+		return exitUsage
+	}
+
+	report, err := buildCatalogReport(*dbPath, time.Now())
+	if err != nil {
+		fmt.Fprintf(stderr, "spcatalog report: %v\n", err)
+		return exitFatal
+	}
+	w, closeOut, err := openOutput(*out, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "spcatalog report: %v\n", err)
+		return exitFatal
+	}
+	if err = closeOut(writeHTMLReport(w, report)); err != nil {
+		fmt.Fprintf(stderr, "spcatalog report: %v\n", err)
+		return exitFatal
+	}
+	if *out != "-" {
+		fmt.Fprintf(stderr, "wrote report to %s\n", *out)
+	}
+	return exitOK
+}
+
+// runDupesCommand finds duplicate files in a catalog.
+func runDupesCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("dupes", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dbPath := flags.String("db", filepath.Join(defaultOutputDir(), "catalog.db"), "catalog to check")
+	format := flags.String("format", "", "text, csv or json (default: from --out's extension, else text)")
+	out := flags.String("out", "-", "output file, or - for stdout")
+	minSize := flags.String("min-size", "", "ignore files smaller than this, e.g. 1MB")
+	noHash := flags.Bool("no-hash", false, "only compare files the catalog already has hashes for")
+	workers := flags.Int("workers", 0, fmt.Sprintf("files hashed in parallel (default %d)", defaultHashWorkers()))
+	top := flags.Int("top", 20, "sets and folders to list in text output")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "spcatalog dupes: unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		return exitUsage
+	}
+	if *format == "" {
+		*format = reportFormatFor(*out)
+	}
+	if *format != "text" && *format != "csv" && *format != "json" {
+		fmt.Fprintf(stderr, "spcatalog dupes: --format must be text, csv or json, not %q\n", *format)
+		return exitUsage
+	}
+	opts := dupeOptions{hashMissing: !*noHash, workers: *workers}
+	if *minSize != "" {
+		n, err := parseSize(*minSize)
+		if err != nil {
+			fmt.Fprintf(stderr, "spcatalog dupes: --min-size: %v\n", err)
+			return exitUsage
+		}
+		opts.minSize = n
+	}
+
+	// Ctrl+C or SIGTERM stops hashing; sums computed so far are stored
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	report, err := findDuplicatesIn(ctx, *dbPath, opts)
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(stderr, "spcatalog dupes: interrupted; the hashes computed so far were stored")
+		return exitPartial
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "spcatalog dupes: %v\n", err)
+		return exitFatal
+	}
+	w, closeOut, err := openOutput(*out, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "spcatalog dupes: %v\n", err)
+		return exitFatal
+	}
+	switch *format {
+	case "csv":
+		err = writeDupesCSV(w, report)
+	case "json":
+		err = writeDupesJSON(w, report)
+	default:
+		writeDupesText(w, report, *top)
+	}
+	if err = closeOut(err); err != nil {
+		fmt.Fprintf(stderr, "spcatalog dupes: %v\n", err)
+		return exitFatal
+	}
+	if *out != "-" {
+		fmt.Fprintf(stderr, "wrote %d duplicate sets (%s in extra copies) to %s\n", len(report.Groups), formatSize(report.Wasted), *out)
+	}
+	return exitOK
+}
+
+// runMismatchesCommand lists cataloged files whose sniffed content type
+// contradicts their extension.
+func runMismatchesCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("mismatches", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dbPath := flags.String("db", filepath.Join(defaultOutputDir(), "catalog.db"), "catalog to check")
+	format := flags.String("format", "", "text, csv or json (default: from --out's extension, else text)")
+	out := flags.String("out", "-", "output file, or - for stdout")
+	top := flags.Int("top", 50, "files to list in text output")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "spcatalog mismatches: unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		return exitUsage
+	}
+	if *format == "" {
+		*format = reportFormatFor(*out)
+	}
+	if *format != "text" && *format != "csv" && *format != "json" {
+		fmt.Fprintf(stderr, "spcatalog mismatches: --format must be text, csv or json, not %q\n", *format)
+		return exitUsage
+	}
+
+	report, err := findMismatchesIn(*dbPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "spcatalog mismatches: %v\n", err)
+		return exitFatal
+	}
+	w, closeOut, err := openOutput(*out, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "spcatalog mismatches: %v\n", err)
+		return exitFatal
+	}
+	switch *format {
+	case "csv":
+		err = writeMismatchesCSV(w, report)
+	case "json":
+		err = writeMismatchesJSON(w, report)
+	default:
+		writeMismatchesText(w, report, *top)
+	}
+	if err = closeOut(err); err != nil {
+		fmt.Fprintf(stderr, "spcatalog mismatches: %v\n", err)
+		return exitFatal
+	}
+	if *out != "-" {
+		fmt.Fprintf(stderr, "wrote %d content mismatches of %d sniffed files to %s\n", len(report.Files), report.Sniffed, *out)
+	}
+	return exitOK
+}
+
+// runVerifyCommand re-hashes cataloged files and reports which no longer
+// match.
+func runVerifyCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dbPath := flags.String("db", filepath.Join(defaultOutputDir(), "catalog.db"), "catalog to verify")
+	format := flags.String("format", "text", "text or json")
+	workers := flags.Int("workers", 0, fmt.Sprintf("files hashed in parallel (default %d)", defaultHashWorkers()))
+	quiet := flags.Bool("quiet", false, "print no progress")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "spcatalog verify: unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		return exitUsage
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "spcatalog verify: --format must be text or json, not %q\n", *format)
+		return exitUsage
+	}
+
+	// Ctrl+C or SIGTERM stops hashing; files checked so far are recorded
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var lastLine time.Time
+	summary, err := verifyCatalog(ctx, *dbPath, verifyOptions{
+		workers: *workers,
+		onProgress: func(done, total int) {
+			if *quiet || time.Since(lastLine) < cliProgressInterval {
+				return
+			}
+			lastLine = time.Now()
+			fmt.Fprintf(stderr, "verified %d of %d files\n", done, total)
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "spcatalog verify: %v\n", err)
+		return exitFatal
+	}
+	if *format == "json" {
+		if err := writeVerifyJSON(stdout, summary); err != nil {
+			fmt.Fprintf(stderr, "spcatalog verify: %v\n", err)
+			return exitFatal
+		}
+	} else {
+		writeVerifyText(stdout, summary)
+	}
+	if !summary.clean() {
+		return exitPartial
+	}
+	return exitOK
+}
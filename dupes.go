@@ -0,0 +1,584 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------- duplicate files ----------
+
+// dupeOptions controls findDuplicates.
+type dupeOptions struct {
+	minSize int64 // ignore files smaller than this; empty files are always ignored
+	// hashMissing hashes size-collision candidates that have no sha256 yet
+	// and stores the result, so a catalog built without hashing can still
+	// be checked without reading every file
+	hashMissing bool
+	workers     int
+	onHash      func(done, total int) // progress while hashing, if set
+}
+
+// duplicateFile is one copy in a duplicateGroup.
+type duplicateFile struct {
+	Path     string    `json:"path"`
+	Folder   string    `json:"folder"`
+	Modified time.Time `json:"mtime_utc"`
+}
+
+// duplicateGroup is a set of files with the same size and SHA256. Files are
+// oldest first; the first is counted as the original and the rest as
+// wasted space.
+type duplicateGroup struct {
+	SHA256 string          `json:"sha256"`
+	Size   int64           `json:"size"`
+	Wasted int64           `json:"wasted"` // space the extra copies take
+	Files  []duplicateFile `json:"files"`
+}
+
+// dupeFolder is the space duplicate copies take in one folder.
+type dupeFolder struct {
+	Folder string `json:"folder"`
+	Copies int64  `json:"copies"`
+	Wasted int64  `json:"wasted"`
+}
+
+// dupeReport is the outcome of findDuplicates.
+type dupeReport struct {
+	Groups  []duplicateGroup `json:"groups"` // most wasted space first
+	Folders []dupeFolder     `json:"folders"`
+	Wasted  int64            `json:"wasted"`
+	// Candidates share their size with another file; Hashed of them were
+	// hashed by this call and Unhashed could not be compared
+	Candidates int `json:"candidates"`
+	Hashed     int `json:"hashed"`
+	Unhashed   int `json:"unhashed"`
+}
+
+// dupeCandidate is a file whose size matches another file's.
+type dupeCandidate struct {
+	path, folder, mtime, sum string
+	size                     int64
+	hashErr                  error
+}
+
+// findDuplicatesIn opens the catalog at dbPath and finds its duplicates.
+// The catalog is only written to when opts.hashMissing stores new hashes.
+// Cancelling ctx stops hashing; the sums computed so far are still stored
+// and ctx.Err() is returned.
+func findDuplicatesIn(ctx context.Context, dbPath string, opts dupeOptions) (*dupeReport, error) {
+	if !opts.hashMissing {
+		db, err := openCatalogReadOnly(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+		return findDuplicates(ctx, db, opts)
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("no catalog at %s: %w", dbPath, err)
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	if err := initSchema(db); err != nil {
+		return nil, err
+	}
+	return findDuplicates(ctx, db, opts)
+}
+
+// findDuplicates groups files by size, then by hash. Only regular files
+// that share their size with another file are candidates, so on a catalog
+// without hashes only those are read.
+func findDuplicates(ctx context.Context, db *sql.DB, opts dupeOptions) (*dupeReport, error) {
+	minSize := max(opts.minSize, 1)
+	rows, err := db.Query(`
+		SELECT f.abs_path, f.folder_path, f.size, COALESCE(f.mtime_utc, ''), COALESCE(f.sha256, '')
+		FROM files f JOIN (
+			SELECT size FROM files
+			WHERE deleted_at IS NULL AND size >= ? AND COALESCE(entry_type, 'file') = 'file'
+			GROUP BY size HAVING COUNT(*) > 1
+		) s ON s.size = f.size
+		WHERE f.deleted_at IS NULL AND COALESCE(f.entry_type, 'file') = 'file'
+		ORDER BY f.size, f.abs_path
+	`, minSize)
+	if err != nil {
+		return nil, err
+	}
+	var cands []dupeCandidate
+	for rows.Next() {
+		var c dupeCandidate
+		if err := rows.Scan(&c.path, &c.folder, &c.size, &c.mtime, &c.sum); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		cands = append(cands, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	r := &dupeReport{Candidates: len(cands)}
+	if opts.hashMissing {
+		if r.Hashed, err = hashCandidates(ctx, db, cands, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	bySum := map[[2]string][]duplicateFile{}
+	for _, c := range cands {
+		if c.sum == "" {
+			r.Unhashed++
+			continue
+		}
+		mt, _ := time.Parse(time.RFC3339, c.mtime)
+		key := [2]string{strconv.FormatInt(c.size, 10), c.sum}
+		bySum[key] = append(bySum[key], duplicateFile{Path: c.path, Folder: c.folder, Modified: mt})
+	}
+
+	folders := map[string]*dupeFolder{}
+	for key, files := range bySum {
+		if len(files) < 2 {
+			continue
+		}
+		sort.Slice(files, func(i, j int) bool {
+			if !files[i].Modified.Equal(files[j].Modified) {
+				return files[i].Modified.Before(files[j].Modified)
+			}
+			return files[i].Path < files[j].Path
+		})
+		size, _ := strconv.ParseInt(key[0], 10, 64)
+		g := duplicateGroup{SHA256: key[1], Size: size, Wasted: int64(len(files)-1) * size, Files: files}
+		r.Groups = append(r.Groups, g)
+		r.Wasted += g.Wasted
+		for _, f := range files[1:] {
+			d := folders[f.Folder]
+			if d == nil {
+				d = &dupeFolder{Folder: f.Folder}
+				folders[f.Folder] = d
+			}
+			d.Copies++
+			d.Wasted += size
+		}
+	}
+	sort.Slice(r.Groups, func(i, j int) bool {
+		a, b := r.Groups[i], r.Groups[j]
+		if a.Wasted != b.Wasted {
+			return a.Wasted > b.Wasted
+		}
+		return a.SHA256 < b.SHA256
+	})
+	for _, d := range folders {
+		r.Folders = append(r.Folders, *d)
+	}
+	sort.Slice(r.Folders, func(i, j int) bool {
+		a, b := r.Folders[i], r.Folders[j]
+		if a.Wasted != b.Wasted {
+			return a.Wasted > b.Wasted
+		}
+		return a.Folder < b.Folder
+	})
+	return r, nil
+}
+
+// hashCandidates hashes the candidates that have no sum, in parallel, and
+// stores the sums. Files that changed since the scan are left unhashed so
+// the catalog never pairs a hash with stale metadata. When ctx is cancelled
+// the files not yet started are skipped, and the sums so far are stored
+// before ctx.Err() is returned.
+func hashCandidates(ctx context.Context, db *sql.DB, cands []dupeCandidate, opts dupeOptions) (int, error) {
+	var todo []int
+	for i, c := range cands {
+		if c.sum == "" {
+			todo = append(todo, i)
+		}
+	}
+	if len(todo) == 0 {
+		return 0, nil
+	}
+
+	workers := opts.workers
+	if workers < 1 {
+		workers = defaultHashWorkers()
+	}
+	jobs := make(chan int)
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				c := &cands[i]
+				if info, err := os.Stat(c.path); err == nil && info.Mode().IsRegular() &&
+					info.Size() == c.size && info.ModTime().UTC().Format(time.RFC3339) == c.mtime {
+					c.sum, c.hashErr = hashFile(c.path)
+				}
+				mu.Lock()
+				done++
+				if opts.onHash != nil {
+					opts.onHash(done, len(todo))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for _, i := range todo {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	stmt, err := tx.Prepare(`UPDATE files SET sha256 = ?, hash_status = ?, hash_error = ?
+		WHERE abs_path = ? AND size = ? AND mtime_utc = ?`)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	defer stmt.Close()
+	hashed := 0
+	for _, i := range todo {
+		c := cands[i]
+		var err error
+		switch {
+		case c.hashErr != nil:
+			_, err = stmt.Exec(nil, hashError, c.hashErr.Error(), c.path, c.size, c.mtime)
+		case c.sum != "":
+			_, err = stmt.Exec(c.sum, hashOK, nil, c.path, c.size, c.mtime)
+			hashed++
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return hashed, ctx.Err()
+}
+
+// writeDupesCSV writes one row per duplicate file. keep marks the copy
+// counted as the original.
+func writeDupesCSV(w io.Writer, r *dupeReport) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"group", "sha256", "size", "group_wasted", "keep", "path", "folder", "mtime_utc"}); err != nil {
+		return err
+	}
+	for i, g := range r.Groups {
+		for j, f := range g.Files {
+			if err := cw.Write([]string{
+				strconv.Itoa(i + 1), g.SHA256, strconv.FormatInt(g.Size, 10), strconv.FormatInt(g.Wasted, 10), strconv.FormatBool(j == 0),
+				f.Path, f.Folder, f.Modified.UTC().Format(time.RFC3339),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeDupesJSON writes the whole report as one JSON document.
+func writeDupesJSON(w io.Writer, r *dupeReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	out := struct {
+		*dupeReport
+		GroupCount int `json:"group_count"`
+	}{r, len(r.Groups)}
+	if out.Groups == nil {
+		out.Groups = []duplicateGroup{}
+	}
+	if out.Folders == nil {
+		out.Folders = []dupeFolder{}
+	}
+	return enc.Encode(out)
+}
+
+// writeDupesText prints a summary with the top groups and folders.
+func writeDupesText(w io.Writer, r *dupeReport, top int) {
+	fmt.Fprintf(w, "duplicate sets: %d, extra copies: %s\n", len(r.Groups), formatSize(r.Wasted))
+	if r.Hashed > 0 {
+		fmt.Fprintf(w, "hashed %d of %d same-size candidates\n", r.Hashed, r.Candidates)
+	}
+	if r.Unhashed > 0 {
+		fmt.Fprintf(w, "%d same-size candidates have no hash and were not compared\n", r.Unhashed)
+	}
+	for i, g := range r.Groups {
+		if i == top {
+			fmt.Fprintf(w, "\n... and %d more sets\n", len(r.Groups)-top)
+			break
+		}
+		fmt.Fprintf(w, "\n%s wasted: %d copies of %s (%s)\n", formatSize(g.Wasted), len(g.Files), formatSize(g.Size), g.SHA256)
+		for j, f := range g.Files {
+			mark := "  "
+			if j == 0 {
+				mark = "* "
+			}
+			fmt.Fprintf(w, "  %s%s\n", mark, f.Path)
+		}
+	}
+	if len(r.Folders) > 0 {
+		fmt.Fprintf(w, "\nFolders with the most duplicate copies:\n")
+		for i, d := range r.Folders {
+			if i == top {
+				break
+			}
+			fmt.Fprintf(w, "  %10s  %4d copies  %s\n", formatSize(d.Wasted), d.Copies, d.Folder)
+		}
+	}
+}
+
+// ---------- duplicates screen ----------
+
+type dupesModel struct {
+	report    *dupeReport
+	byFolder  bool // list folders instead of sets
+	selected  int
+	hashed    int // progress while hashing candidates
+	hashTotal int
+	cancel    context.CancelFunc // stops hashing; nil once the search is done
+	stopping  bool               // leave once the stopped search reports back
+	err       string
+	notice    string
+}
+
+type dupesMsg struct {
+	report *dupeReport
+	err    error
+}
+
+type dupesProgressMsg struct{ done, total int }
+
+type dupesExportMsg struct {
+	path string
+	err  error
+}
+
+// openDupes finds the catalog's duplicates, hashing same-size candidates
+// that have no hash yet and reporting progress through send.
+func openDupes(ctx context.Context, dbPath string, send func(tea.Msg)) tea.Cmd {
+	return func() tea.Msg {
+		r, err := findDuplicatesIn(ctx, dbPath, dupeOptions{
+			hashMissing: true,
+			onHash: func(done, total int) {
+				if done == total || done%25 == 0 {
+					send(dupesProgressMsg{done: done, total: total})
+				}
+			},
+		})
+		return dupesMsg{report: r, err: err}
+	}
+}
+
+func (d dupesModel) count() int {
+	if d.report == nil {
+		return 0
+	}
+	if d.byFolder {
+		return len(d.report.Folders)
+	}
+	return len(d.report.Groups)
+}
+
+func (m model) updateDupes(msg tea.Msg) (tea.Model, tea.Cmd) {
+	d := &m.dupes
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.windowSize = msg
+		return m, nil
+	case dupesProgressMsg:
+		d.hashed, d.hashTotal = msg.done, msg.total
+		return m, nil
+	case dupesMsg:
+		d.cancel = nil
+		if d.stopping {
+			m.state = stateDone
+			return m, nil
+		}
+		if msg.err != nil {
+			d.err = msg.err.Error()
+			return m, nil
+		}
+		d.report, d.selected = msg.report, 0
+		return m, nil
+	case dupesExportMsg:
+		if msg.err != nil {
+			d.notice = "Export failed: " + msg.err.Error()
+		} else {
+			d.notice = "Exported duplicate sets to " + msg.path
+		}
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc":
+			// Wait for hashing to stop, so a new search never writes
+			// the catalog alongside it; hashes so far are kept
+			if d.cancel != nil {
+				d.cancel()
+				d.stopping = true
+				return m, nil
+			}
+			m.state = stateDone
+			return m, nil
+		case "?", "h", "F1":
+			m.help.previousState = m.state
+			m.state = stateHelp
+			return m, nil
+		case "up", "k":
+			if d.selected > 0 {
+				d.selected--
+			}
+		case "down", "j":
+			if d.selected < d.count()-1 {
+				d.selected++
+			}
+		case "pgup":
+			d.selected = max(d.selected-m.getBrowserDisplayLines(), 0)
+		case "pgdown":
+			d.selected = max(min(d.selected+m.getBrowserDisplayLines(), d.count()-1), 0)
+		case "tab":
+			d.byFolder = !d.byFolder
+			d.selected = 0
+		case "e":
+			if d.report == nil {
+				return m, nil
+			}
+			r, path := d.report, filepath.Join(filepath.Dir(m.dbPath), "duplicates.csv")
+			return m, func() tea.Msg {
+				f, err := os.Create(path)
+				if err != nil {
+					return dupesExportMsg{err: err}
+				}
+				err = writeDupesCSV(f, r)
+				if cerr := f.Close(); err == nil {
+					err = cerr
+				}
+				return dupesExportMsg{path: path, err: err}
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m model) viewDupes() string {
+	var b strings.Builder
+	d := m.dupes
+
+	fmt.Fprintf(&b, "%s\n\n",
+		lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#7c3aed")).Render("♊ Duplicate Files"))
+
+	if d.err != "" {
+		errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ef4444")).Bold(true)
+		fmt.Fprintf(&b, "%s %s\n\n", errorStyle.Render("⚠ Error:"), d.err)
+		fmt.Fprintf(&b, "%s\n", lbl.Render("Press ESC to go back"))
+		return b.String()
+	}
+	if d.report == nil {
+		if d.stopping {
+			fmt.Fprintf(&b, "%s\n", lbl.Render("Stopping; keeping the hashes computed so far..."))
+		} else if d.hashTotal > 0 {
+			fmt.Fprintf(&b, "%s\n", lbl.Render(fmt.Sprintf("Hashing same-size files... %d of %d", d.hashed, d.hashTotal)))
+		} else {
+			fmt.Fprintf(&b, "%s\n", lbl.Render("Looking for duplicates..."))
+		}
+		return b.String()
+	}
+
+	r := d.report
+	fmt.Fprintf(&b, "%s %s\n", val.Render("Duplicate sets:"), acc.Render(formatCount(int64(len(r.Groups)))))
+	fmt.Fprintf(&b, "%s %s\n", val.Render("Extra copies:"), acc.Render(formatSize(r.Wasted)))
+	if r.Unhashed > 0 {
+		fmt.Fprintf(&b, "%s\n", bad.Render(fmt.Sprintf("%d same-size files could not be hashed and were not compared", r.Unhashed)))
+	}
+	fmt.Fprintln(&b)
+
+	maxDisplay := m.getBrowserDisplayLines()
+	start := 0
+	if d.selected >= maxDisplay {
+		start = d.selected - maxDisplay + 1
+	}
+	end := min(start+maxDisplay, d.count())
+	pathWidth := max(m.getTableWidth()-36, 16)
+	mark := func(row []string, i int) []string {
+		if i == d.selected {
+			row[0] = "▸"
+			for j := range row {
+				row[j] = acc.Render(row[j])
+			}
+		}
+		return row
+	}
+
+	switch {
+	case d.count() == 0 && d.byFolder:
+		fmt.Fprintf(&b, "%s\n", lbl.Render("No folders hold duplicate copies"))
+	case d.count() == 0:
+		fmt.Fprintf(&b, "%s\n", lbl.Render("No duplicate files found"))
+	case d.byFolder:
+		var rows [][]string
+		for i := start; i < end; i++ {
+			f := r.Folders[i]
+			rows = append(rows, mark([]string{" ", formatSize(f.Wasted), formatCount(f.Copies), m.wrapText(f.Folder, pathWidth)}, i))
+		}
+		fmt.Fprintf(&b, "%s\n", renderTable([]string{" ", "Wasted", "Copies", "Folder"}, rows))
+	default:
+		var rows [][]string
+		for i := start; i < end; i++ {
+			g := r.Groups[i]
+			rows = append(rows, mark([]string{" ", formatSize(g.Wasted), formatCount(int64(len(g.Files))), formatSize(g.Size),
+				m.wrapText(filepath.Base(g.Files[0].Path), pathWidth)}, i))
+		}
+		fmt.Fprintf(&b, "%s\n", renderTable([]string{" ", "Wasted", "Copies", "Size", "File"}, rows))
+	}
+	if d.count() > maxDisplay {
+		fmt.Fprintf(&b, "\n%s\n", lbl.Render(fmt.Sprintf("(%d-%d of %d)", start+1, end, d.count())))
+	}
+
+	if !d.byFolder && d.count() > 0 {
+		g := r.Groups[d.selected]
+		fmt.Fprintf(&b, "\n%s %s\n", val.Render("SHA256:"), lbl.Render(g.SHA256))
+		for i, f := range g.Files {
+			keep := "  "
+			if i == 0 {
+				keep = ok.Render("* ")
+			}
+			fmt.Fprintf(&b, "%s%s %s\n", keep, m.wrapText(f.Path, m.getWidth()-16), lbl.Render(f.Modified.Local().Format("2006-01-02")))
+		}
+	}
+
+	if d.notice != "" {
+		fmt.Fprintf(&b, "\n%s\n", d.notice)
+	}
+	fmt.Fprintf(&b, "\n%s\n", lbl.Render("↑/↓ or j/k select • Tab sets/folders • e export CSV • * oldest copy, kept • ESC to go back"))
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// eventWriter emits the NDJSON events of `spcatalog scan --json`: one
+// object per line, each with an "event" and a "time" field.
+type eventWriter struct {
+	enc *json.Encoder
+}
+
+func newEventWriter(w io.Writer) *eventWriter {
+	return &eventWriter{enc: json.NewEncoder(w)}
+}
+
+type eventHeader struct {
+	Event string `json:"event"`
+	Time  string `json:"time"`
+}
+
+func header(event string) eventHeader {
+	return eventHeader{Event: event, Time: time.Now().UTC().Format(time.RFC3339Nano)}
+}
+
+// emit writes one event. Output errors are ignored: a closed pipe must not
+// fail the scan.
+func (w *eventWriter) emit(v any) {
+	_ = w.enc.Encode(v)
+}
+
+func (w *eventWriter) started(runID, resumedFrom, dbPath string, opts scanOptions) {
+	var options json.RawMessage
+	if o := opts.toJSON(); o != "" {
+		options = json.RawMessage(o)
+	}
+	w.emit(struct {
+		eventHeader
+		RunID       string          `json:"run_id"`
+		ResumedFrom string          `json:"resumed_from,omitempty"`
+		Root        string          `json:"root"`
+		Database    string          `json:"database"`
+		Options     json.RawMessage `json:"options,omitempty"`
+	}{header("started"), runID, resumedFrom, opts.root, dbPath, options})
+}
+
+func (w *eventWriter) progress(p progressMsg, elapsed time.Duration) {
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(p.files) / elapsed.Seconds()
+	}
+	w.emit(struct {
+		eventHeader
+		RunID          string  `json:"run_id"`
+		Files          int64   `json:"files"`
+		Folders        int64   `json:"folders"`
+		Bytes          int64   `json:"bytes"`
+		EstimatedTotal int64   `json:"estimated_total,omitempty"`
+		TotalFinal     bool    `json:"total_final"`
+		Errors         int64   `json:"errors"`
+		Path           string  `json:"path,omitempty"`
+		Rate           float64 `json:"rate_files_per_sec"`
+		ETASeconds     float64 `json:"eta_seconds,omitempty"`
+		ElapsedSeconds float64 `json:"elapsed_seconds"`
+	}{
+		header("progress"), p.runID, p.files, p.folders, p.bytes, p.estimatedTotal, p.totalFinal, p.errors, p.last,
+		rate, remainingTime(p.files, p.estimatedTotal, elapsed).Seconds(), elapsed.Seconds(),
+	})
+}
+
+func (w *eventWriter) scanError(runID string, e scanErrorRecord) {
+	w.emit(struct {
+		eventHeader
+		RunID string `json:"run_id"`
+		Path  string `json:"path"`
+		Op    string `json:"op"`
+		Error string `json:"error"`
+	}{header("error"), runID, e.path, e.op, e.err})
+}
+
+func (w *eventWriter) summary(s scanSummary) {
+	w.emit(struct {
+		eventHeader
+		scanSummary
+	}{header("summary"), s})
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------- catalog explorer ----------
+
+// folderTree is the folder hierarchy of a catalog with recursive totals,
+// built from folders.parent_path and the files in each folder.
+type folderTree struct {
+	children map[string][]string // folder → subfolders
+	mtime    map[string]time.Time
+	totals   map[string]*folderTotal
+}
+
+// folderTotal counts everything beneath a folder, at any depth.
+type folderTotal struct {
+	files, folders, bytes int64
+}
+
+// loadFolderTree reads every cataloged folder that is not flagged as
+// deleted and totals the files beneath it.
+func loadFolderTree(db *sql.DB) (*folderTree, error) {
+	t := &folderTree{
+		children: map[string][]string{},
+		mtime:    map[string]time.Time{},
+		totals:   map[string]*folderTotal{},
+	}
+	parents := map[string]string{}
+	rows, err := db.Query(`SELECT path, COALESCE(parent_path, ''), mtime_utc FROM folders WHERE deleted_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var path, parent string
+		var mtime sql.NullString
+		if err := rows.Scan(&path, &parent, &mtime); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		parents[path] = parent
+		t.totals[path] = &folderTotal{}
+		t.mtime[path], _ = time.Parse(time.RFC3339, mtime.String)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for path, parent := range parents {
+		if _, ok := parents[parent]; ok && parent != path {
+			t.children[parent] = append(t.children[parent], path)
+			t.add(parent, folderTotal{folders: 1})
+		}
+	}
+
+	rows, err = db.Query(`
+		SELECT folder_path, COUNT(*), COALESCE(SUM(size), 0)
+		FROM files WHERE deleted_at IS NULL GROUP BY folder_path
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path string
+		var d folderTotal
+		if err := rows.Scan(&path, &d.files, &d.bytes); err != nil {
+			return nil, err
+		}
+		if _, ok := t.totals[path]; ok {
+			t.add(path, d)
+		}
+	}
+	return t, rows.Err()
+}
+
+// add adds d to folder p and every cataloged folder above it.
+func (t *folderTree) add(p string, d folderTotal) {
+	for {
+		tot := t.totals[p]
+		if tot == nil {
+			return
+		}
+		tot.files += d.files
+		tot.folders += d.folders
+		tot.bytes += d.bytes
+		parent := filepath.Dir(p)
+		if parent == p {
+			return
+		}
+		p = parent
+	}
+}
+
+// explorerSort is the order of the explorer listing.
+type explorerSort int
+
+const (
+	sortBySize explorerSort = iota // largest first
+	sortByDate                     // newest first
+	sortByName
+)
+
+var explorerSortNames = [...]string{sortBySize: "size", sortByDate: "date", sortByName: "name"}
+
+func (s explorerSort) String() string { return explorerSortNames[s] }
+
+func (s explorerSort) next() explorerSort {
+	return (s + 1) % explorerSort(len(explorerSortNames))
+}
+
+// explorerEntry is one row of the explorer listing.
+type explorerEntry struct {
+	name     string
+	path     string
+	isDir    bool
+	size     int64 // recursive for folders
+	files    int64 // files beneath a folder
+	modified time.Time
+}
+
+type explorerModel struct {
+	root     string // the scan root; the explorer does not go above it
+	current  string
+	tree     *folderTree
+	entries  []explorerEntry
+	selected int
+	sortBy   explorerSort
+	loading  bool
+	err      string
+}
+
+type explorerTreeMsg struct {
+	root string
+	tree *folderTree
+	err  error
+}
+
+type explorerListMsg struct {
+	path    string
+	entries []explorerEntry
+	err     error
+}
+
+// openExplorer loads the folder tree of the catalog. The explorer starts at
+// the root of run runID, or of the latest run if runID is empty.
+func openExplorer(dbPath, runID string) tea.Cmd {
+	return func() tea.Msg {
+		db, err := openCatalogReadOnly(dbPath)
+		if err != nil {
+			return explorerTreeMsg{err: err}
+		}
+		defer db.Close()
+		var root string
+		if runID != "" {
+			err = db.QueryRow(`SELECT root FROM scan_runs WHERE id = ?`, runID).Scan(&root)
+		} else {
+			err = db.QueryRow(`SELECT root FROM scan_runs ORDER BY started_utc DESC, id DESC LIMIT 1`).Scan(&root)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return explorerTreeMsg{err: errors.New("the catalog has no scans yet")}
+		}
+		if err != nil {
+			return explorerTreeMsg{err: err}
+		}
+		tree, err := loadFolderTree(db)
+		return explorerTreeMsg{root: root, tree: tree, err: err}
+	}
+}
+
+// loadExplorerEntries lists folder path: its subfolders from the tree and
+// its files from the catalog.
+func loadExplorerEntries(dbPath string, tree *folderTree, path string, by explorerSort) tea.Cmd {
+	return func() tea.Msg {
+		var entries []explorerEntry
+		for _, sub := range tree.children[path] {
+			tot := tree.totals[sub]
+			entries = append(entries, explorerEntry{
+				name: filepath.Base(sub), path: sub, isDir: true,
+				size: tot.bytes, files: tot.files, modified: tree.mtime[sub],
+			})
+		}
+
+		db, err := openCatalogReadOnly(dbPath)
+		if err != nil {
+			return explorerListMsg{path: path, err: err}
+		}
+		defer db.Close()
+		rows, err := db.Query(`
+			SELECT abs_path, name, COALESCE(size, 0), mtime_utc FROM files
+			WHERE folder_path = ? AND deleted_at IS NULL
+		`, path)
+		if err != nil {
+			return explorerListMsg{path: path, err: err}
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var e explorerEntry
+			var mtime sql.NullString
+			if err := rows.Scan(&e.path, &e.name, &e.size, &mtime); err != nil {
+				return explorerListMsg{path: path, err: err}
+			}
+			e.modified, _ = time.Parse(time.RFC3339, mtime.String)
+			entries = append(entries, e)
+		}
+		if err := rows.Err(); err != nil {
+			return explorerListMsg{path: path, err: err}
+		}
+		sortExplorerEntries(entries, by)
+		return explorerListMsg{path: path, entries: entries}
+	}
+}
+
+// sortExplorerEntries puts folders before files, each ordered by by, with
+// names breaking ties.
+func sortExplorerEntries(entries []explorerEntry, by explorerSort) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.isDir != b.isDir {
+			return a.isDir
+		}
+		switch by {
+		case sortBySize:
+			if a.size != b.size {
+				return a.size > b.size
+			}
+		case sortByDate:
+			if !a.modified.Equal(b.modified) {
+				return a.modified.After(b.modified)
+			}
+		}
+		return strings.ToLower(a.name) < strings.ToLower(b.name)
+	})
+}
+
+func (m model) updateExplorer(msg tea.Msg) (tea.Model, tea.Cmd) {
+	ex := &m.explorer
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.windowSize = msg
+		return m, nil
+	case explorerTreeMsg:
+		if msg.err != nil {
+			ex.loading = false
+			ex.err = msg.err.Error()
+			return m, nil
+		}
+		ex.root, ex.current, ex.tree = msg.root, msg.root, msg.tree
+		return m, loadExplorerEntries(m.dbPath, ex.tree, ex.current, ex.sortBy)
+	case explorerListMsg:
+		ex.loading = false
+		if msg.err != nil {
+			ex.err = msg.err.Error()
+			return m, nil
+		}
+		previous := ex.current
+		ex.current, ex.err = msg.path, ""
+		ex.entries = msg.entries
+		if ex.current != ex.root {
+			ex.entries = append([]explorerEntry{{name: "..", path: filepath.Dir(ex.current), isDir: true}}, ex.entries...)
+		}
+		// Coming back up, keep the folder we left selected
+		ex.selected = 0
+		for i, e := range ex.entries {
+			if e.name != ".." && e.path == previous {
+				ex.selected = i
+			}
+		}
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc":
+			// Back to the results screen
+			m.state = stateDone
+			return m, nil
+		case "?", "h", "F1":
+			m.help.previousState = m.state
+			m.state = stateHelp
+			return m, nil
+		case "up", "k":
+			if ex.selected > 0 {
+				ex.selected--
+			}
+		case "down", "j":
+			if ex.selected < len(ex.entries)-1 {
+				ex.selected++
+			}
+		case "enter":
+			if ex.tree == nil || ex.loading || len(ex.entries) == 0 {
+				return m, nil
+			}
+			if e := ex.entries[ex.selected]; e.isDir {
+				ex.loading = true
+				return m, loadExplorerEntries(m.dbPath, ex.tree, e.path, ex.sortBy)
+			}
+		case "backspace", "left":
+			if ex.tree != nil && !ex.loading && ex.current != ex.root {
+				ex.loading = true
+				return m, loadExplorerEntries(m.dbPath, ex.tree, filepath.Dir(ex.current), ex.sortBy)
+			}
+		case "s":
+			ex.sortBy = ex.sortBy.next()
+			if len(ex.entries) == 0 {
+				return m, nil
+			}
+			selected := ex.entries[ex.selected].path
+			listed := ex.entries
+			if listed[0].name == ".." {
+				listed = listed[1:]
+			}
+			sortExplorerEntries(listed, ex.sortBy)
+			for i, e := range ex.entries {
+				if e.path == selected {
+					ex.selected = i
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m model) viewExplorer() string {
+	var b strings.Builder
+	ex := m.explorer
+
+	fmt.Fprintf(&b, "%s\n\n",
+		lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#7c3aed")).Render("🗂  Catalog Explorer"))
+
+	if ex.err != "" {
+		errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ef4444")).Bold(true)
+		fmt.Fprintf(&b, "%s %s\n\n", errorStyle.Render("⚠ Error:"), ex.err)
+		fmt.Fprintf(&b, "%s\n", lbl.Render("Press ESC to go back"))
+		return b.String()
+	}
+	if ex.tree == nil {
+		fmt.Fprintf(&b, "%s\n", lbl.Render("Loading catalog..."))
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "%s %s\n",
+		lipgloss.NewStyle().Foreground(lipgloss.Color("#94a3b8")).Bold(true).Render("Folder:"),
+		acc.Render(m.wrapText(ex.current, m.getWidth()-12)))
+	if tot := ex.tree.totals[ex.current]; tot != nil {
+		fmt.Fprintf(&b, "%s\n", lbl.Render(fmt.Sprintf("%s in %d files and %d folders • sorted by %s",
+			formatSize(tot.bytes), tot.files, tot.folders, ex.sortBy)))
+	}
+	fmt.Fprintln(&b)
+
+	if len(ex.entries) == 0 {
+		fmt.Fprintf(&b, "%s\n", lbl.Render("Empty folder"))
+	} else {
+		nameWidth := max(m.getTableWidth()-36, 16)
+		maxDisplay := m.getBrowserDisplayLines()
+		start := 0
+		if ex.selected >= maxDisplay {
+			start = ex.selected - maxDisplay + 1
+		}
+		end := min(start+maxDisplay, len(ex.entries))
+
+		dirStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#10b981"))
+		fileStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#f1f5f9"))
+		for i := start; i < end; i++ {
+			e := ex.entries[i]
+			prefix := "  "
+			if i == ex.selected {
+				prefix = acc.Render("▸ ")
+			}
+			if e.name == ".." {
+				fmt.Fprintf(&b, "%s%s\n", prefix, lbl.Render("../"))
+				continue
+			}
+			name, style, count := e.name, fileStyle, ""
+			if e.isDir {
+				name, style, count = name+"/", dirStyle, fmt.Sprintf("%d files", e.files)
+			}
+			modified := ""
+			if !e.modified.IsZero() {
+				modified = e.modified.Local().Format("2006-01-02")
+			}
+			fmt.Fprintf(&b, "%s%s %10s %12s %10s\n", prefix,
+				style.Render(fmt.Sprintf("%-*s", nameWidth, m.wrapText(name, nameWidth))),
+				val.Render(formatSize(e.size)), lbl.Render(count), lbl.Render(modified))
+		}
+		if len(ex.entries) > maxDisplay {
+			fmt.Fprintf(&b, "\n%s\n", lbl.Render(fmt.Sprintf("(%d-%d of %d)", start+1, end, len(ex.entries))))
+		}
+	}
+
+	fmt.Fprintf(&b, "\n%s\n", lbl.Render("↑/↓ or j/k navigate • Enter to open folder • Backspace up • s sort by size/date/name • ESC to go back"))
+	return b.String()
+}
@@ -0,0 +1,592 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// exportColumn is one column available to exports, with the SQL that
+// produces it from files f LEFT JOIN folders d.
+type exportColumn struct {
+	name   string
+	expr   string
+	folder bool       // needs the folders join
+	kind   columnKind // cell type in XLSX exports
+}
+
+type columnKind int
+
+const (
+	columnText columnKind = iota
+	columnNumber
+	columnDate // RFC3339 text in the catalog
+)
+
+// exportColumns lists every exportable column in default order. Folder
+// columns are only available when exporting with the folders join.
+var exportColumns = []exportColumn{
+	{"abs_path", "f.abs_path", false, columnText},
+	{"folder_path", "f.folder_path", false, columnText},
+	{"name", "f.name", false, columnText},
+	{"ext", "f.ext", false, columnText},
+	{"size", "f.size", false, columnNumber},
+	{"mtime_utc", "f.mtime_utc", false, columnDate},
+	{"mime", "f.mime", false, columnText},
+	{"sha256", "f.sha256", false, columnText},
+	{"sha1", "f.sha1", false, columnText},
+	{"md5", "f.md5", false, columnText},
+	{"quickxor", "f.quickxor", false, columnText},
+	{"hash_status", "f.hash_status", false, columnText},
+	{"hash_error", "f.hash_error", false, columnText},
+	{"sniffed_mime", "f.sniffed_mime", false, columnText},
+	{"entry_type", "f.entry_type", false, columnText},
+	{"link_target", "f.link_target", false, columnText},
+	{"seen_run", "f.seen_run", false, columnText},
+	{"deleted_at", "f.deleted_at", false, columnDate},
+	{"deleted_run", "f.deleted_run", false, columnText},
+	{"folder_parent_path", "d.parent_path", true, columnText},
+	{"folder_mtime_utc", "d.mtime_utc", true, columnDate},
+}
+
+// defaultExportColumns is what an export contains when no columns are given.
+var defaultExportColumns = []string{"abs_path", "folder_path", "name", "ext", "size", "mtime_utc", "mime", "sha256", "entry_type", "link_target"}
+
+// exportOptions controls exportCatalog.
+type exportOptions struct {
+	format         string   // "csv", "tsv" or "xlsx"
+	columns        []string // empty means defaultExportColumns
+	where          string   // SQL condition over the exported column names
+	withFolders    bool     // join folders, adding the folder_* columns
+	includeDeleted bool     // include rows flagged as deleted
+	bom            bool     // start CSV output with a UTF-8 BOM so Excel detects the encoding
+}
+
+// parseColumnList splits a comma-separated column list.
+func parseColumnList(s string) []string {
+	var cols []string
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+// exportQuery builds the SELECT for opts. Columns are checked against
+// exportColumns; the where condition is applied to an inner query that
+// exposes every column under its export name, so it can refer to folder
+// columns without ambiguity.
+func exportQuery(opts exportOptions) (query string, cols []string, err error) {
+	cols = opts.columns
+	if len(cols) == 0 {
+		cols = defaultExportColumns
+	}
+	known := map[string]bool{}
+	for _, c := range exportColumns {
+		if !c.folder || opts.withFolders {
+			known[c.name] = true
+		}
+	}
+	for _, c := range cols {
+		if !known[c] {
+			if strings.HasPrefix(c, "folder_") && !opts.withFolders {
+				return "", nil, fmt.Errorf("column %q needs the folders join", c)
+			}
+			return "", nil, fmt.Errorf("unknown column %q", c)
+		}
+	}
+	return "SELECT " + strings.Join(cols, ", ") + " FROM " + exportSource(opts) + " ORDER BY abs_path", cols, nil
+}
+
+// exportSource is the FROM clause of an export: the files opts selects,
+// with every column under its export name.
+func exportSource(opts exportOptions) string {
+	var inner []string
+	for _, c := range exportColumns {
+		if !c.folder || opts.withFolders {
+			inner = append(inner, c.expr+" AS "+c.name)
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "(SELECT %s FROM files f", strings.Join(inner, ", "))
+	if opts.withFolders {
+		b.WriteString(" LEFT JOIN folders d ON d.path = f.folder_path")
+	}
+	if !opts.includeDeleted {
+		b.WriteString(" WHERE f.deleted_at IS NULL")
+	}
+	b.WriteString(")")
+	if w := strings.TrimSpace(opts.where); w != "" {
+		fmt.Fprintf(&b, " WHERE (%s)", w)
+	}
+	return b.String()
+}
+
+// openCatalogReadOnly opens dbPath for reports and exports. The file is
+// opened read-only and the connection is query-only, so neither a
+// user-supplied filter nor reading a catalog written by an older version
+// changes it, and catalogs the user cannot write can still be read.
+func openCatalogReadOnly(dbPath string) (*sql.DB, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("no catalog at %s: %w", dbPath, err)
+	}
+	path := filepath.ToSlash(dbPath)
+	if filepath.VolumeName(dbPath) != "" {
+		path = "/" + path // file:/C:/... on Windows
+	}
+	db, err := sql.Open("sqlite", "file:"+(&url.URL{Path: path}).EscapedPath()+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	// One connection, so the temporary tables below and query_only stick
+	db.SetMaxOpenConns(1)
+	if err := shimCatalogSchema(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.Exec(`PRAGMA query_only = ON`); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// catalogSchema returns the tables initSchema creates, with their columns,
+// by running it against an in-memory database.
+var catalogSchema = sync.OnceValues(func() (map[string][]tableColumn, error) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if err := initSchema(db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	schema := map[string][]tableColumn{}
+	for _, t := range tables {
+		if schema[t], err = tableColumns(db, "main", t); err != nil {
+			return nil, err
+		}
+	}
+	return schema, nil
+})
+
+// shimCatalogSchema lets queries written for the current schema run against
+// a catalog from an older version without migrating it. A missing table is
+// stood in for by an empty temporary one, and a table missing columns by a
+// temporary view that adds them as NULL; temporary objects take precedence
+// over the catalog's own and live only as long as the connection.
+func shimCatalogSchema(db *sql.DB) error {
+	want, err := catalogSchema()
+	if err != nil {
+		return err
+	}
+	for table, cols := range want {
+		have, err := tableColumns(db, "main", table)
+		if err != nil {
+			return err
+		}
+		var stmt string
+		if len(have) == 0 {
+			defs := make([]string, len(cols))
+			for i, c := range cols {
+				defs[i] = c.name + " " + c.typ
+			}
+			stmt = fmt.Sprintf(`CREATE TEMP TABLE %s (%s)`, table, strings.Join(defs, ", "))
+		} else {
+			present := map[string]bool{}
+			for _, c := range have {
+				present[c.name] = true
+			}
+			var missing []string
+			for _, c := range cols {
+				if !present[c.name] {
+					missing = append(missing, "NULL AS "+c.name)
+				}
+			}
+			if len(missing) == 0 {
+				continue
+			}
+			stmt = fmt.Sprintf(`CREATE TEMP VIEW %s AS SELECT *, %s FROM main.%s`, table, strings.Join(missing, ", "), table)
+		}
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportCatalog writes the files table of the catalog at dbPath to w as CSV,
+// TSV or an XLSX workbook, with a header row, and returns the number of
+// file rows.
+func exportCatalog(dbPath string, w io.Writer, opts exportOptions) (int64, error) {
+	var comma rune
+	switch opts.format {
+	case "", "csv":
+		comma = ','
+	case "tsv":
+		comma = '\t'
+	case "xlsx":
+		return exportXLSX(dbPath, w, opts)
+	default:
+		return 0, fmt.Errorf("unknown format %q", opts.format)
+	}
+	query, cols, err := exportQuery(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	db, err := openCatalogReadOnly(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	rows, err := db.Query(query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	bw := bufio.NewWriter(w)
+	if opts.bom && comma == ',' {
+		bw.WriteString("\ufeff")
+	}
+	cw := csv.NewWriter(bw)
+	cw.Comma = comma
+	if err := cw.Write(cols); err != nil {
+		return 0, err
+	}
+
+	values := make([]sql.NullString, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	record := make([]string, len(cols))
+	var n int64
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return n, err
+		}
+		for i, v := range values {
+			record[i] = v.String
+		}
+		if err := cw.Write(record); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// exportFormatFor picks the export format implied by an output file name.
+func exportFormatFor(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tsv", ".tab":
+		return "tsv"
+	case ".xlsx":
+		return "xlsx"
+	}
+	return "csv"
+}
+
+// exportCatalogFile writes the default export of dbPath to path, in the
+// format its extension implies.
+func exportCatalogFile(dbPath, path string) (int64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := exportCatalog(dbPath, f, exportOptions{format: exportFormatFor(path), bom: true})
+	return n, closeOutputFile(f, err)
+}
+
+// closeOutputFile closes f, an export or report being written, and returns
+// err or else the error from closing. On any error it removes the file, since
+// a partly written export would look like a complete one.
+func closeOutputFile(f *os.File, err error) error {
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// exportXLSX writes a workbook with the selected files, their folders, and
+// totals by extension and by top-level folder. Sizes are numbers and
+// timestamps are Excel dates (UTC), so the sheets sort and filter properly.
+func exportXLSX(dbPath string, w io.Writer, opts exportOptions) (int64, error) {
+	query, cols, err := exportQuery(opts)
+	if err != nil {
+		return 0, err
+	}
+	kinds := make([]columnKind, len(cols))
+	for i, name := range cols {
+		for _, c := range exportColumns {
+			if c.name == name {
+				kinds[i] = c.kind
+			}
+		}
+	}
+
+	db, err := openCatalogReadOnly(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	x := newXLSXWriter(w)
+	source := exportSource(opts)
+
+	// Files
+	widths := make([]float64, len(cols))
+	for i, name := range cols {
+		switch name {
+		case "abs_path", "folder_path", "link_target", "folder_parent_path":
+			widths[i] = 60
+		case "name":
+			widths[i] = 40
+		case "mime":
+			widths[i] = 24
+		case "sha256":
+			widths[i] = 66
+		}
+		if kinds[i] == columnDate {
+			widths[i] = 20
+		}
+	}
+	if err := x.startTable("Files", cols, widths); err != nil {
+		return 0, err
+	}
+	n, err := writeXLSXRows(db, x, query, kinds)
+	if err != nil {
+		return n, err
+	}
+	if err := x.endSheet(); err != nil {
+		return n, err
+	}
+
+	// Folders, with the totals of the selected files directly inside them
+	folders := `SELECT d.path, d.parent_path, d.mtime_utc, COALESCE(s.files, 0), COALESCE(s.bytes, 0)
+		FROM folders d LEFT JOIN (SELECT folder_path, COUNT(*) AS files, SUM(size) AS bytes FROM ` + source + ` GROUP BY folder_path) s
+		ON s.folder_path = d.path`
+	if !opts.includeDeleted {
+		folders += ` WHERE d.deleted_at IS NULL`
+	}
+	folders += ` ORDER BY d.path`
+	if err := x.startTable("Folders", []string{"path", "parent_path", "mtime_utc", "files", "bytes"}, []float64{60, 60, 20, 10, 16}); err != nil {
+		return n, err
+	}
+	if _, err := writeXLSXRows(db, x, folders, []columnKind{columnText, columnText, columnDate, columnNumber, columnNumber}); err != nil {
+		return n, err
+	}
+	if err := x.endSheet(); err != nil {
+		return n, err
+	}
+
+	// By extension
+	byExt := `SELECT COALESCE(NULLIF(ext, ''), '(none)'), COUNT(*), COALESCE(SUM(size), 0), MAX(mtime_utc)
+		FROM ` + source + ` GROUP BY 1 ORDER BY 3 DESC, 1`
+	if err := x.startTable("By Extension", []string{"extension", "files", "bytes", "newest_mtime_utc"}, []float64{14, 10, 16, 20}); err != nil {
+		return n, err
+	}
+	if _, err := writeXLSXRows(db, x, byExt, []columnKind{columnText, columnNumber, columnNumber, columnDate}); err != nil {
+		return n, err
+	}
+	if err := x.endSheet(); err != nil {
+		return n, err
+	}
+
+	// By top-level folder
+	tops, err := topFolderTotals(db, source)
+	if err != nil {
+		return n, err
+	}
+	if err := x.startTable("By Top Folder", []string{"root", "folder", "files", "bytes", "newest_mtime_utc"}, []float64{40, 40, 10, 16, 20}); err != nil {
+		return n, err
+	}
+	for _, t := range tops {
+		if err := x.row(t.root, t.folder, t.files, t.bytes, t.newest); err != nil {
+			return n, err
+		}
+	}
+	if err := x.endSheet(); err != nil {
+		return n, err
+	}
+	return n, x.close()
+}
+
+// writeXLSXRows appends the rows of query to the current sheet, converting
+// each column according to kinds, and returns how many it wrote.
+func writeXLSXRows(db *sql.DB, x *xlsxWriter, query string, kinds []columnKind) (int64, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	values := make([]sql.NullString, len(kinds))
+	ptrs := make([]any, len(kinds))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	cells := make([]any, len(kinds))
+	var n int64
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return n, err
+		}
+		for i, v := range values {
+			cells[i] = xlsxValue(v, kinds[i])
+		}
+		if err := x.row(cells...); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
+
+// xlsxValue types a catalog value for a cell, keeping the text when it
+// does not parse.
+func xlsxValue(v sql.NullString, kind columnKind) any {
+	if !v.Valid {
+		return nil
+	}
+	switch kind {
+	case columnNumber:
+		if n, err := strconv.ParseInt(v.String, 10, 64); err == nil {
+			return n
+		}
+	case columnDate:
+		if t, err := time.Parse(time.RFC3339, v.String); err == nil {
+			return t
+		}
+	}
+	return v.String
+}
+
+// topFolderTotal sums the files under one top-level folder of a scan root.
+type topFolderTotal struct {
+	root, folder string
+	files, bytes int64
+	newest       time.Time
+}
+
+// topFolderTotals groups the files of source by the first folder below the
+// scan root they were found under, largest first. Files directly in a root
+// are grouped as "(root)".
+func topFolderTotals(db *sql.DB, source string) ([]topFolderTotal, error) {
+	var roots []string
+	rows, err := db.Query(`SELECT DISTINCT root FROM scan_runs`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var r string
+		if err := rows.Scan(&r); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		roots = append(roots, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Longest first, so nested roots claim their own files
+	sort.Slice(roots, func(i, j int) bool { return len(roots[i]) > len(roots[j]) })
+
+	totals := map[[2]string]*topFolderTotal{}
+	rows, err = db.Query(`SELECT abs_path, COALESCE(size, 0), mtime_utc FROM ` + source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path string
+		var size int64
+		var mtime sql.NullString
+		if err := rows.Scan(&path, &size, &mtime); err != nil {
+			return nil, err
+		}
+		root, folder := "", filepath.Dir(path)
+		for _, r := range roots {
+			if isWithin(r, path) {
+				root, folder = r, "(root)"
+				if rel, err := filepath.Rel(r, path); err == nil {
+					if first, _, nested := strings.Cut(rel, string(filepath.Separator)); nested {
+						folder = first
+					}
+				}
+				break
+			}
+		}
+		key := [2]string{root, folder}
+		t := totals[key]
+		if t == nil {
+			t = &topFolderTotal{root: root, folder: folder}
+			totals[key] = t
+		}
+		t.files++
+		t.bytes += size
+		if mt, err := time.Parse(time.RFC3339, mtime.String); err == nil && mt.After(t.newest) {
+			t.newest = mt
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]topFolderTotal, 0, len(totals))
+	for _, t := range totals {
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].bytes != out[j].bytes {
+			return out[i].bytes > out[j].bytes
+		}
+		if out[i].root != out[j].root {
+			return out[i].root < out[j].root
+		}
+		return out[i].folder < out[j].folder
+	})
+	return out, nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileFilter restricts a scan to files within a size range and a
+// modification window. Lower bounds are inclusive and upper bounds are
+// exclusive; zero values mean unbounded.
+type fileFilter struct {
+	sizeAtLeast    int64
+	sizeBelow      int64
+	modifiedSince  time.Time
+	modifiedBefore time.Time
+}
+
+func (f fileFilter) active() bool {
+	return f.sizeAtLeast > 0 || f.sizeBelow > 0 || !f.modifiedSince.IsZero() || !f.modifiedBefore.IsZero()
+}
+
+func (f fileFilter) match(info fs.FileInfo) bool {
+	size := info.Size()
+	if size < f.sizeAtLeast || (f.sizeBelow > 0 && size >= f.sizeBelow) {
+		return false
+	}
+	mt := info.ModTime()
+	if !f.modifiedSince.IsZero() && mt.Before(f.modifiedSince) {
+		return false
+	}
+	if !f.modifiedBefore.IsZero() && !mt.Before(f.modifiedBefore) {
+		return false
+	}
+	return true
+}
+
+// parseFileFilter parses the form's size and modified fields. Both take
+// comma-separated terms that must all hold. now anchors relative ages.
+func parseFileFilter(size, modified string, now time.Time) (fileFilter, error) {
+	var f fileFilter
+	for _, term := range splitTerms(size) {
+		if err := f.addSizeTerm(term); err != nil {
+			return fileFilter{}, err
+		}
+	}
+	for _, term := range splitTerms(modified) {
+		if err := f.addModifiedTerm(term, now); err != nil {
+			return fileFilter{}, err
+		}
+	}
+	if f.sizeBelow > 0 && f.sizeAtLeast >= f.sizeBelow {
+		return fileFilter{}, fmt.Errorf("size filter %q matches nothing", size)
+	}
+	if !f.modifiedSince.IsZero() && !f.modifiedBefore.IsZero() && !f.modifiedSince.Before(f.modifiedBefore) {
+		return fileFilter{}, fmt.Errorf("modified filter %q matches nothing", modified)
+	}
+	return f, nil
+}
+
+func splitTerms(s string) []string {
+	var out []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (f *fileFilter) setSizeAtLeast(n int64) {
+	if n > f.sizeAtLeast {
+		f.sizeAtLeast = n
+	}
+}
+
+func (f *fileFilter) setSizeBelow(n int64) {
+	if f.sizeBelow == 0 || n < f.sizeBelow {
+		f.sizeBelow = n
+	}
+}
+
+func (f *fileFilter) setModifiedSince(t time.Time) {
+	if t.After(f.modifiedSince) {
+		f.modifiedSince = t
+	}
+}
+
+func (f *fileFilter) setModifiedBefore(t time.Time) {
+	if f.modifiedBefore.IsZero() || t.Before(f.modifiedBefore) {
+		f.modifiedBefore = t
+	}
+}
+
+// addSizeTerm handles ">10MB", ">=10MB", "<1GB", "<=1GB" and "10MB-1GB".
+func (f *fileFilter) addSizeTerm(term string) error {
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if !strings.HasPrefix(term, op) {
+			continue
+		}
+		n, err := parseSize(term[len(op):])
+		if err != nil {
+			return err
+		}
+		switch op {
+		case ">=":
+			f.setSizeAtLeast(n)
+		case ">":
+			f.setSizeAtLeast(n + 1)
+		case "<=":
+			f.setSizeBelow(n + 1)
+		case "<":
+			if n == 0 {
+				return fmt.Errorf("size filter %q matches nothing", term)
+			}
+			f.setSizeBelow(n)
+		}
+		return nil
+	}
+	lo, hi, ok := strings.Cut(term, "-")
+	if !ok {
+		return fmt.Errorf("size filter %q: use >N, >=N, <N, <=N or N-M", term)
+	}
+	min, err := parseSize(lo)
+	if err != nil {
+		return err
+	}
+	max, err := parseSize(hi)
+	if err != nil {
+		return err
+	}
+	f.setSizeAtLeast(min)
+	f.setSizeBelow(max + 1)
+	return nil
+}
+
+// parseSize parses "512", "10KB", "1.5 GB" and the like, in 1024-based units
+// to match formatSize.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	num := strings.TrimRight(s, "KMGTPIB ")
+	unit := strings.TrimSpace(s[len(num):])
+	mult := int64(1)
+	switch strings.TrimSuffix(strings.TrimSuffix(unit, "B"), "I") {
+	case "":
+	case "K":
+		mult = 1 << 10
+	case "M":
+		mult = 1 << 20
+	case "G":
+		mult = 1 << 30
+	case "T":
+		mult = 1 << 40
+	case "P":
+		mult = 1 << 50
+	default:
+		return 0, fmt.Errorf("size %q: unknown unit %q", s, unit)
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("size %q: not a size", s)
+	}
+	if v*float64(mult) >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q: too large", s)
+	}
+	return int64(v * float64(mult)), nil
+}
+
+// addModifiedTerm handles "since 2024-01-01", "before 2024-01-01", ">=",
+// ">", "<", "<=" with a date, "2023-01-01..2024-01-01", and relative ages
+// "older than 3y" and "newer than 30d".
+func (f *fileFilter) addModifiedTerm(term string, now time.Time) error {
+	lower := strings.ToLower(term)
+	for _, prefix := range []string{"older than ", "newer than ", "within "} {
+		if !strings.HasPrefix(lower, prefix) {
+			continue
+		}
+		t, err := parseAge(lower[len(prefix):], now)
+		if err != nil {
+			return err
+		}
+		if prefix == "older than " {
+			f.setModifiedBefore(t)
+		} else {
+			f.setModifiedSince(t)
+		}
+		return nil
+	}
+	for _, prefix := range []string{"since ", "before ", ">=", "<=", ">", "<"} {
+		if !strings.HasPrefix(lower, prefix) {
+			continue
+		}
+		t, err := parseDate(lower[len(prefix):])
+		if err != nil {
+			return err
+		}
+		// Dates are whole days, so "> day" starts after it and
+		// "<= day" includes it
+		switch prefix {
+		case "since ", ">=":
+			f.setModifiedSince(t)
+		case ">":
+			f.setModifiedSince(t.AddDate(0, 0, 1))
+		case "before ", "<":
+			f.setModifiedBefore(t)
+		case "<=":
+			f.setModifiedBefore(t.AddDate(0, 0, 1))
+		}
+		return nil
+	}
+	if from, to, ok := strings.Cut(lower, ".."); ok {
+		since, err := parseDate(from)
+		if err != nil {
+			return err
+		}
+		until, err := parseDate(to)
+		if err != nil {
+			return err
+		}
+		f.setModifiedSince(since)
+		f.setModifiedBefore(until.AddDate(0, 0, 1))
+		return nil
+	}
+	return fmt.Errorf("modified filter %q: use since/before DATE, older/newer than AGE or DATE..DATE", term)
+}
+
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("date %q: use YYYY-MM-DD", s)
+}
+
+// parseAge turns "3y", "18 months", "2w" or "30d" into the time that long
+// before now.
+func parseAge(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	n, err := strconv.Atoi(s[:i])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("age %q: use a number and unit, e.g. 3y or 30d", s)
+	}
+	switch strings.TrimSpace(s[i:]) {
+	case "y", "yr", "yrs", "year", "years":
+		return now.AddDate(-n, 0, 0), nil
+	case "mo", "month", "months":
+		return now.AddDate(0, -n, 0), nil
+	case "w", "week", "weeks":
+		return now.AddDate(0, 0, -7*n), nil
+	case "d", "day", "days":
+		return now.AddDate(0, 0, -n), nil
+	}
+	return time.Time{}, fmt.Errorf("age %q: unit must be y, mo, w or d", s)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// ---------- hash algorithms ----------
+
+// hashAlgorithm is a checksum the scanner can compute. Each has its own
+// files column, and all selected ones are computed in a single read.
+type hashAlgorithm struct {
+	name   string // as listed in --hash-algos and on the form
+	column string
+	new    func() hash.Hash
+	encode func([]byte) string
+}
+
+// hashAlgorithms is every algorithm the scanner knows, in column order.
+// SharePoint and OneDrive report quickXorHash in base64, as stored here, and
+// sha1Hash and sha256Hash in upper-case hex, so compare those ignoring case.
+var hashAlgorithms = []hashAlgorithm{
+	{"sha256", "sha256", sha256.New, hex.EncodeToString},
+	{"sha1", "sha1", sha1.New, hex.EncodeToString},
+	{"md5", "md5", md5.New, hex.EncodeToString},
+	{"quickxor", "quickxor", newQuickXorHash, base64.StdEncoding.EncodeToString},
+}
+
+// defaultHashAlgorithms is what a scan with hashing on computes when no
+// algorithms are chosen.
+var defaultHashAlgorithms = []string{"sha256"}
+
+// lookupHashAlgorithm finds an algorithm by name, ignoring case and dashes,
+// so "SHA-256" and "quickXorHash" work too.
+func lookupHashAlgorithm(name string) (hashAlgorithm, bool) {
+	name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "")
+	if name == "quickxorhash" {
+		name = "quickxor"
+	}
+	for _, a := range hashAlgorithms {
+		if a.name == name {
+			return a, true
+		}
+	}
+	return hashAlgorithm{}, false
+}
+
+// parseHashAlgorithms parses a comma-separated list such as
+// "sha256,quickxor", or "all". It returns names in column order without
+// repeats; an empty list means defaultHashAlgorithms.
+func parseHashAlgorithms(s string) ([]string, error) {
+	chosen := map[string]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if strings.EqualFold(part, "all") {
+			for _, a := range hashAlgorithms {
+				chosen[a.name] = true
+			}
+			continue
+		}
+		a, ok := lookupHashAlgorithm(part)
+		if !ok {
+			return nil, fmt.Errorf("unknown hash algorithm %q (want %s)", part, hashAlgorithmNames())
+		}
+		chosen[a.name] = true
+	}
+	if len(chosen) == 0 {
+		return defaultHashAlgorithms, nil
+	}
+	var names []string
+	for _, a := range hashAlgorithms {
+		if chosen[a.name] {
+			names = append(names, a.name)
+		}
+	}
+	return names, nil
+}
+
+// hashAlgorithmNames lists the known algorithms for messages.
+func hashAlgorithmNames() string {
+	names := make([]string, len(hashAlgorithms))
+	for i, a := range hashAlgorithms {
+		names[i] = a.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// hashFileWith reads path once and returns the sum of each named algorithm,
+// keyed by name. A read that fails part way returns the error, never sums
+// of the partial stream.
+func hashFileWith(path string, names []string) (map[string]string, error) {
+	hashes := make([]hash.Hash, len(names))
+	writers := make([]io.Writer, len(names))
+	algos := make([]hashAlgorithm, len(names))
+	for i, name := range names {
+		a, ok := lookupHashAlgorithm(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown hash algorithm %q", name)
+		}
+		algos[i], hashes[i] = a, a.new()
+		writers[i] = hashes[i]
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return nil, err
+	}
+	sums := make(map[string]string, len(names))
+	for i, a := range algos {
+		sums[a.name] = a.encode(hashes[i].Sum(nil))
+	}
+	return sums, nil
+}
+
+// ---------- QuickXorHash ----------
+
+// quickXorWidth is the size of a QuickXorHash in bytes (160 bits).
+const quickXorWidth = 20
+
+// quickXorShift is how many bits each input byte is shifted past the
+// previous one, wrapping around the 160-bit state.
+const quickXorShift = 11
+
+// quickXorHash is Microsoft's QuickXorHash, the checksum OneDrive and
+// SharePoint report for every file. Input byte k is XORed into the 160-bit
+// little-endian state at bit (k*11) mod 160, and the total length, as a
+// little-endian int64, is XORed into the last 8 bytes of the result.
+type quickXorHash struct {
+	state  [quickXorWidth]byte
+	bit    int // where the next byte goes
+	length int64
+}
+
+func newQuickXorHash() hash.Hash { return &quickXorHash{} }
+
+func (q *quickXorHash) Write(p []byte) (int, error) {
+	bit := q.bit
+	for _, c := range p {
+		i, s := bit>>3, uint(bit&7)
+		q.state[i] ^= c << s
+		if s != 0 {
+			q.state[(i+1)%quickXorWidth] ^= c >> (8 - s)
+		}
+		bit += quickXorShift
+		if bit >= quickXorWidth*8 {
+			bit -= quickXorWidth * 8
+		}
+	}
+	q.bit = bit
+	q.length += int64(len(p))
+	return len(p), nil
+}
+
+func (q *quickXorHash) Sum(b []byte) []byte {
+	out := q.state
+	var length [8]byte
+	binary.LittleEndian.PutUint64(length[:], uint64(q.length))
+	for i, c := range length {
+		out[quickXorWidth-8+i] ^= c
+	}
+	return append(b, out[:]...)
+}
+
+func (q *quickXorHash) Reset()         { *q = quickXorHash{} }
+func (q *quickXorHash) Size() int      { return quickXorWidth }
+func (q *quickXorHash) BlockSize() int { return 64 }
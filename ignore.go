@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFileName is read from every scanned directory. Its patterns apply to
+// that directory and everything below it.
+const ignoreFileName = ".spcatalogignore"
+
+// ignoreRule is one gitignore-style pattern.
+type ignoreRule struct {
+	segments []string // pattern split on "/"
+	negate   bool     // "!pattern" re-includes a path
+	dirOnly  bool     // "pattern/" only matches directories
+	anchored bool     // contains a "/", so it matches relative to base
+	base     string   // directory the pattern is relative to
+}
+
+// ignoreMatcher decides which paths a scan skips. Global rules come from the
+// form or config and are relative to the root; per-directory rules come from
+// .spcatalogignore files. As in git, later rules win and deeper files are
+// consulted after shallower ones.
+type ignoreMatcher struct {
+	root   string
+	global []ignoreRule
+	perDir map[string][]ignoreRule
+}
+
+func newIgnoreMatcher(root string, patterns []string) *ignoreMatcher {
+	m := &ignoreMatcher{root: filepath.Clean(root), perDir: map[string][]ignoreRule{}}
+	for _, p := range patterns {
+		if r, ok := parseIgnoreRule(p, m.root); ok {
+			m.global = append(m.global, r)
+		}
+	}
+	return m
+}
+
+// parseExcludeList splits the form/config exclude field on commas and
+// newlines.
+func parseExcludeList(s string) []string {
+	var out []string
+	for _, p := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func parseIgnoreRule(line, base string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	line = strings.TrimLeft(line, " \t")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	r := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:] // "\#" and "\!" escape a literal first character
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	r.segments = strings.Split(line, "/")
+	return r, true
+}
+
+// loadDir reads dir's .spcatalogignore, if any.
+func (m *ignoreMatcher) loadDir(dir string) error {
+	f, err := os.Open(filepath.Join(dir, ignoreFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		// A folder that cannot be read at all is reported once, by the
+		// walk's readdir error
+		d, derr := os.Open(dir)
+		if derr != nil {
+			return nil
+		}
+		d.Close()
+		return err
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if r, ok := parseIgnoreRule(sc.Text(), dir); ok {
+			rules = append(rules, r)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if len(rules) > 0 {
+		m.perDir[filepath.Clean(dir)] = rules
+	}
+	return nil
+}
+
+// excluded reports whether p should be skipped. The root is never excluded.
+func (m *ignoreMatcher) excluded(p string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	p = filepath.Clean(p)
+	if p == m.root {
+		return false
+	}
+
+	// Per-directory rules from the root down to p's parent
+	var dirs []string
+	for d := filepath.Dir(p); ; d = filepath.Dir(d) {
+		dirs = append(dirs, d)
+		if d == m.root || d == filepath.Dir(d) {
+			break
+		}
+	}
+
+	ignored := false
+	apply := func(rules []ignoreRule) {
+		for _, r := range rules {
+			if r.matches(p, isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+	apply(m.global)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		apply(m.perDir[dirs[i]])
+	}
+	return ignored
+}
+
+func (r ignoreRule) matches(p string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(r.base, p)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if !r.anchored {
+		// A bare pattern matches the name at any depth
+		return matchSegments(r.segments, parts[len(parts)-1:])
+	}
+	return matchSegments(r.segments, parts)
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches zero or more whole segments.
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			pat = pat[1:]
+			if len(pat) == 0 {
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pat, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
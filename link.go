@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// symlinkPolicy decides what a scan does with symlinks and junctions.
+type symlinkPolicy int
+
+const (
+	linkRecord symlinkPolicy = iota // catalog the link itself, with its target
+	linkIgnore                      // leave links out of the catalog
+	linkFollow                      // catalog what the link points to, descending into folders
+)
+
+var symlinkPolicyNames = [...]string{linkRecord: "record", linkIgnore: "ignore", linkFollow: "follow"}
+
+func (p symlinkPolicy) String() string {
+	if p < 0 || int(p) >= len(symlinkPolicyNames) {
+		return fmt.Sprintf("symlinkPolicy(%d)", int(p))
+	}
+	return symlinkPolicyNames[p]
+}
+
+// next cycles through the policies for the form toggle.
+func (p symlinkPolicy) next() symlinkPolicy {
+	return (p + 1) % symlinkPolicy(len(symlinkPolicyNames))
+}
+
+// lookupSymlinkPolicy maps a policy name back to its value.
+func lookupSymlinkPolicy(s string) (symlinkPolicy, bool) {
+	for i, name := range symlinkPolicyNames {
+		if s == name {
+			return symlinkPolicy(i), true
+		}
+	}
+	return linkRecord, false
+}
+
+// parseSymlinkPolicy maps a saved name back to a policy, defaulting to
+// linkRecord.
+func parseSymlinkPolicy(s string) symlinkPolicy {
+	p, _ := lookupSymlinkPolicy(s)
+	return p
+}
+
+// Values of files.entry_type.
+const (
+	entryFile    = "file"
+	entrySymlink = "symlink"
+	entryOther   = "other" // devices, sockets, pipes: recorded but never read
+)
+
+func entryTypeOf(mode fs.FileMode) string {
+	switch {
+	case isLink(mode):
+		return entrySymlink
+	case mode.IsRegular():
+		return entryFile
+	}
+	return entryOther
+}
+
+// isLink reports symlinks and, on Windows, junctions and other reparse
+// points, which os reports as irregular rather than as symlinks.
+func isLink(mode fs.FileMode) bool {
+	if mode&fs.ModeSymlink != 0 {
+		return true
+	}
+	return runtime.GOOS == "windows" && mode&fs.ModeIrregular != 0
+}
+
+// linkFollower decides which directory links a follow-policy walk descends
+// into. A link is not followed when its target contains the link, which
+// would loop forever, or when another link to the same target was already
+// followed. Those links are cataloged as links instead.
+type linkFollower struct {
+	followed map[string]bool // resolved targets already walked
+}
+
+func newLinkFollower() *linkFollower {
+	return &linkFollower{followed: map[string]bool{}}
+}
+
+// follow returns the entry to walk in place of the link at p, or nil to
+// leave the link as a leaf.
+func (f *linkFollower) follow(p string, d fs.DirEntry) fs.DirEntry {
+	info, err := os.Stat(p)
+	if err != nil || !info.IsDir() {
+		return nil
+	}
+	target, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return nil
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(p))
+	if err != nil {
+		return nil
+	}
+	if isWithin(target, parent) || f.followed[target] {
+		return nil
+	}
+	f.followed[target] = true
+	return fs.FileInfoToDirEntry(info)
+}
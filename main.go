@@ -1,14 +1,13 @@
 package main
 
 import (
-	"crypto/sha256"
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"mime"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,15 +27,28 @@ const (
 	stateScanning
 	stateDone
 	stateHelp
+	stateExplorer
+	stateSearch
+	stateDupes
+	stateVerify
 )
 
 type formModel struct {
-	root   textinput.Model // required
-	outDir textinput.Model // optional (defaults to $HOME/spcatalog)
-	ext    textinput.Model // optional: ".pdf,.docx"
-	hashOn bool
-
-	focus int // 0=root, 1=outDir, 2=ext
+	root     textinput.Model // required
+	outDir   textinput.Model // optional (defaults to $HOME/spcatalog)
+	ext      textinput.Model // optional: ".pdf,.docx"
+	exclude  textinput.Model // optional: "node_modules, ~$*, .git/"
+	size     textinput.Model // optional: ">10MB", "1MB-1GB"
+	modified textinput.Model // optional: "older than 3y", "since 2024-01-01"
+	workers  textinput.Model // optional: hashing worker count
+	algos    textinput.Model // optional: "sha256,quickxor"
+	hashOn   bool
+	incrOn   bool // incremental rescan
+	sniffOn  bool // sniff content types from file headers
+	resume   bool // continue the last unfinished run of this root
+	symlinks symlinkPolicy
+
+	focus int // 0=root, 1=outDir, 2=ext, 3=exclude, 4=size, 5=modified, 6=workers, 7=algos
 	err   string
 
 	// Autocomplete state
@@ -52,6 +64,13 @@ type formModel struct {
 	recentPaths []string
 }
 
+// formFieldCount is the number of text inputs on the form.
+const formFieldCount = 8
+
+// formFirstFilterField is the focus index of the exclude field; it and the
+// fields after it accept typed text that would otherwise trigger shortcuts.
+const formFirstFilterField = 3
+
 type browserModel struct {
 	currentPath string
 	entries     []os.DirEntry
@@ -67,21 +86,51 @@ type model struct {
 	state      appState
 	form       formModel
 	browser    browserModel
+	explorer   explorerModel
+	search     searchModel
+	dupes      dupesModel
+	verify     verifyModel
 	help       helpModel
 	spin       spinner.Model
 	start      time.Time
+	finished   time.Time
 	stats      stats
 	dbPath     string
 	err        error
 	windowSize tea.WindowSizeMsg
+	sender     *programSender // delivers scanner messages to the running program
+	cancel     context.CancelFunc
+	stopping   bool   // cancel requested, waiting for the scan to commit
+	cancelled  bool   // scan ended early at the user's request
+	notice     string // result of the last action on the done screen
+	askPurge   bool   // the notice asks whether to purge; y purges
+	scanErrors []scanErrorRecord
 }
 
 type stats struct {
+	runID          string // scan_runs.id of the scan
+	resumedFrom    string // checkpoint the scan resumed after, if any
 	files          int64
 	folders        int64
+	bytes          int64
 	last           string
-	estimatedTotal int64   // Estimated total files to process
+	estimatedTotal int64   // Files expected, from the previous run until the walk ends
+	discovered     int64   // Files the walk has found so far
+	totalFinal     bool    // The walk has finished, so estimatedTotal is exact
 	progress       float64 // Progress percentage (0-100)
+
+	// Incremental scan breakdown of files
+	newFiles       int64
+	changedFiles   int64
+	unchangedFiles int64
+
+	// Rows flagged as deleted because the walk no longer found them
+	deletedFiles   int64
+	deletedFolders int64
+
+	errors     int64 // paths that could not be read, see scan_errors
+	hashErrors int64 // files whose content could not be hashed, also counted in errors
+	mismatches int64 // files sniffed by this scan whose content contradicts their extension
 }
 
 // Configuration for persistent settings
@@ -91,12 +140,52 @@ type appConfig struct {
 	LastRootPath    string   `json:"last_root_path"`
 	LastOutputDir   string   `json:"last_output_dir"`
 	LastExtFilter   string   `json:"last_ext_filter"`
+	LastExclude     string   `json:"last_exclude"`
+	LastSizeFilter  string   `json:"last_size_filter"`
+	LastModified    string   `json:"last_modified_filter"`
 	LastHashSetting bool     `json:"last_hash_setting"`
+	HashAlgorithms  string   `json:"hash_algorithms"`
+	HashWorkers     int      `json:"hash_workers"`
+	LastIncremental bool     `json:"last_incremental"`
+	LastSniff       bool     `json:"last_sniff"`
+	LastSymlinks    string   `json:"last_symlinks"`
 }
 
 type progressMsg stats
-type estimationMsg struct{ totalFiles int64 }
-type doneMsg struct{ err error }
+type doneMsg struct {
+	err        error
+	scanErrors []scanErrorRecord // first few errors recorded by the run
+}
+
+// exportMsg reports an export started from the done screen.
+type exportMsg struct {
+	path string
+	rows int64
+	err  error
+}
+
+type purgeMsg struct {
+	files, folders int64
+	err            error
+}
+
+// purgePendingMsg carries the rows a purge would remove, so the done screen
+// can ask before removing them.
+type purgePendingMsg purgeMsg
+
+// programSender lets background work deliver messages to the tea.Program
+// once it exists. The model is copied into the program, so it holds a
+// pointer that main fills in after tea.NewProgram.
+type programSender struct {
+	p *tea.Program
+}
+
+func (s *programSender) Send(msg tea.Msg) {
+	if s == nil || s.p == nil {
+		return
+	}
+	s.p.Send(msg)
+}
 
 var (
 	lbl = lipgloss.NewStyle().Faint(true)
@@ -107,6 +196,14 @@ var (
 )
 
 func main() {
+	// Commands, or a missing terminal, run headless
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+	}
+	if !stdoutIsTerminal() {
+		os.Exit(runSavedScan(os.Stdout, os.Stderr))
+	}
+
 	home, _ := os.UserHomeDir()
 	defaultOut := filepath.Join(home, "spcatalog")
 
@@ -138,6 +235,33 @@ func main() {
 		ext.SetValue(config.LastExtFilter)
 	}
 
+	exclude := textinput.New()
+	exclude.Prompt = "Exclude (optional, gitignore-style): "
+	exclude.Placeholder = "node_modules, ~$*, .git/, Forms/"
+	if config.LastExclude != "" {
+		exclude.SetValue(config.LastExclude)
+	}
+
+	size := textinput.New()
+	size.Prompt = "Size filter (optional, e.g. >10MB): "
+	size.SetValue(config.LastSizeFilter)
+
+	modified := textinput.New()
+	modified.Prompt = "Modified (optional, e.g. older than 3y): "
+	modified.SetValue(config.LastModified)
+
+	workers := textinput.New()
+	workers.Prompt = "Hash workers (optional): "
+	workers.Placeholder = fmt.Sprintf("%d", defaultHashWorkers())
+	if config.HashWorkers > 0 {
+		workers.SetValue(fmt.Sprintf("%d", config.HashWorkers))
+	}
+
+	algos := textinput.New()
+	algos.Prompt = "Hash algorithms (optional): "
+	algos.Placeholder = "sha256 (or sha1, md5, quickxor, all)"
+	algos.SetValue(config.HashAlgorithms)
+
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 
@@ -147,14 +271,25 @@ func main() {
 			root:        root,
 			outDir:      outDir,
 			ext:         ext,
+			exclude:     exclude,
+			size:        size,
+			modified:    modified,
+			workers:     workers,
+			algos:       algos,
 			hashOn:      config.LastHashSetting, // Use saved hash setting
+			incrOn:      config.LastIncremental,
+			sniffOn:     config.LastSniff,
+			symlinks:    parseSymlinkPolicy(config.LastSymlinks),
 			focus:       0,
 			recentPaths: config.RecentPaths,
 		},
-		spin: s,
+		spin:   s,
+		sender: &programSender{},
 	}
 
-	if _, err := tea.NewProgram(m).Run(); err != nil {
+	p := tea.NewProgram(m)
+	m.sender.p = p
+	if _, err := p.Run(); err != nil {
 		fmt.Println("error:", err)
 		os.Exit(1)
 	}
@@ -173,12 +308,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case stateScanning:
 		return m.updateScan(msg)
 	case stateDone:
-		if _, ok := msg.(tea.KeyMsg); ok {
-			return m, tea.Quit
-		}
-		return m, nil
+		return m.updateDone(msg)
 	case stateHelp:
 		return m.updateHelp(msg)
+	case stateExplorer:
+		return m.updateExplorer(msg)
+	case stateSearch:
+		return m.updateSearch(msg)
+	case stateDupes:
+		return m.updateDupes(msg)
+	case stateVerify:
+		return m.updateVerify(msg)
 	default:
 		return m, nil
 	}
@@ -188,6 +328,11 @@ func (m model) updateForm(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		// Filter fields take free text such as "older than 3y", so letters,
+		// digits and spaces typed there are input, not shortcuts
+		if m.form.focus >= formFirstFilterField && (msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace) {
+			break
+		}
 		switch msg.String() {
 		case "tab":
 			// Tab completion for path fields
@@ -195,25 +340,46 @@ func (m model) updateForm(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m.handleTabCompletion()
 			}
 			// Otherwise, move to next field
-			m.form.focus = (m.form.focus + 1) % 3
+			m.form.focus = (m.form.focus + 1) % formFieldCount
 			m.setFocus()
 		case "down":
 			if m.form.showingCompletions && len(m.form.completions) > 0 {
 				m.form.completionIndex = (m.form.completionIndex + 1) % len(m.form.completions)
 				return m, nil
 			}
-			m.form.focus = (m.form.focus + 1) % 3
+			m.form.focus = (m.form.focus + 1) % formFieldCount
 			m.setFocus()
 		case "shift+tab", "up":
 			if m.form.showingCompletions && len(m.form.completions) > 0 {
 				m.form.completionIndex = (m.form.completionIndex + len(m.form.completions) - 1) % len(m.form.completions)
 				return m, nil
 			}
-			m.form.focus = (m.form.focus + 2) % 3
+			m.form.focus = (m.form.focus + formFieldCount - 1) % formFieldCount
 			m.setFocus()
 		case " ":
 			// toggle hash
 			m.form.hashOn = !m.form.hashOn
+		case "ctrl+t":
+			// toggle incremental rescan
+			m.form.incrOn = !m.form.incrOn
+		case "ctrl+o":
+			// toggle sniffing content types from file headers
+			m.form.sniffOn = !m.form.sniffOn
+		case "ctrl+r":
+			// toggle resuming the last interrupted run
+			m.form.resume = !m.form.resume
+		case "ctrl+l":
+			// cycle how symlinks and junctions are handled
+			m.form.symlinks = m.form.symlinks.next()
+		case "ctrl+f":
+			// search the catalog in the output dir, from an earlier scan
+			outDir := strings.TrimSpace(m.form.outDir.Value())
+			if outDir == "" {
+				outDir = defaultOutputDir()
+			}
+			m.state = stateSearch
+			m.search = newSearchModel(filepath.Join(outDir, "catalog.db"), stateForm)
+			return m, m.scheduleSearch()
 		case "ctrl+b":
 			// open directory browser starting from current path context
 			startPath := m.getBrowserStartPath()
@@ -246,6 +412,25 @@ func (m model) updateForm(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			dbPath := filepath.Join(outDir, "catalog.db")
 			extSet := parseExtSet(strings.TrimSpace(m.form.ext.Value()))
+			filter, err := parseFileFilter(m.form.size.Value(), m.form.modified.Value(), time.Now())
+			if err != nil {
+				m.form.err = err.Error()
+				return m, nil
+			}
+			var hashWorkers int
+			if v := strings.TrimSpace(m.form.workers.Value()); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 {
+					m.form.err = "Hash workers must be a positive number."
+					return m, nil
+				}
+				hashWorkers = n
+			}
+			hashAlgos, err := parseHashAlgorithms(m.form.algos.Value())
+			if err != nil {
+				m.form.err = err.Error()
+				return m, nil
+			}
 
 			// Save all preferences before starting scan
 			config := &appConfig{
@@ -254,14 +439,36 @@ func (m model) updateForm(msg tea.Msg) (tea.Model, tea.Cmd) {
 				LastRootPath:    root,
 				LastOutputDir:   outDir,
 				LastExtFilter:   strings.TrimSpace(m.form.ext.Value()),
+				LastExclude:     strings.TrimSpace(m.form.exclude.Value()),
+				LastSizeFilter:  strings.TrimSpace(m.form.size.Value()),
+				LastModified:    strings.TrimSpace(m.form.modified.Value()),
 				LastHashSetting: m.form.hashOn,
+				HashAlgorithms:  strings.TrimSpace(m.form.algos.Value()),
+				HashWorkers:     hashWorkers,
+				LastIncremental: m.form.incrOn,
+				LastSniff:       m.form.sniffOn,
+				LastSymlinks:    m.form.symlinks.String(),
 			}
 			saveConfig(config) // Ignore errors for config saving
 
+			ctx, cancel := context.WithCancel(context.Background())
+			m.cancel = cancel
 			m.dbPath = dbPath
 			m.state = stateScanning
 			m.start = time.Now()
-			return m, tea.Batch(m.spin.Tick, runScan(root, dbPath, extSet, m.form.hashOn))
+			return m, tea.Batch(m.spin.Tick, runScan(ctx, m.sender.Send, dbPath, scanOptions{
+				root:        root,
+				extFilter:   extSet,
+				exclude:     parseExcludeList(m.form.exclude.Value()),
+				filter:      filter,
+				hash:        m.form.hashOn,
+				hashAlgos:   hashAlgos,
+				hashWorkers: hashWorkers,
+				sniff:       m.form.sniffOn,
+				incremental: m.form.incrOn,
+				resume:      m.form.resume,
+				symlinks:    m.form.symlinks,
+			}))
 		case "esc":
 			// Clear completions if showing, otherwise quit
 			if m.form.showingCompletions {
@@ -303,6 +510,16 @@ func (m model) updateForm(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.form.outDirPathValid = validatePath(m.form.outDir.Value())
 	case 2:
 		m.form.ext, cmd = m.form.ext.Update(msg)
+	case 3:
+		m.form.exclude, cmd = m.form.exclude.Update(msg)
+	case 4:
+		m.form.size, cmd = m.form.size.Update(msg)
+	case 5:
+		m.form.modified, cmd = m.form.modified.Update(msg)
+	case 6:
+		m.form.workers, cmd = m.form.workers.Update(msg)
+	case 7:
+		m.form.algos, cmd = m.form.algos.Update(msg)
 	}
 	return m, cmd
 }
@@ -311,6 +528,11 @@ func (m *model) setFocus() {
 	m.form.root.Blur()
 	m.form.outDir.Blur()
 	m.form.ext.Blur()
+	m.form.exclude.Blur()
+	m.form.size.Blur()
+	m.form.modified.Blur()
+	m.form.workers.Blur()
+	m.form.algos.Blur()
 
 	// Clear completions when changing focus
 	m.form.showingCompletions = false
@@ -323,6 +545,16 @@ func (m *model) setFocus() {
 		m.form.outDir.Focus()
 	case 2:
 		m.form.ext.Focus()
+	case 3:
+		m.form.exclude.Focus()
+	case 4:
+		m.form.size.Focus()
+	case 5:
+		m.form.modified.Focus()
+	case 6:
+		m.form.workers.Focus()
+	case 7:
+		m.form.algos.Focus()
 	}
 }
 
@@ -621,14 +853,24 @@ func (m model) updateScan(msg tea.Msg) (tea.Model, tea.Cmd) {
 		var cmd tea.Cmd
 		m.spin, cmd = m.spin.Update(msg)
 		return m, cmd
-	case estimationMsg:
-		m.stats.estimatedTotal = msg.totalFiles
-		return m, nil
 	case progressMsg:
+		m.stats.runID = msg.runID
+		m.stats.resumedFrom = msg.resumedFrom
 		m.stats.files = msg.files
 		m.stats.folders = msg.folders
+		m.stats.bytes = msg.bytes
+		m.stats.newFiles = msg.newFiles
+		m.stats.changedFiles = msg.changedFiles
+		m.stats.unchangedFiles = msg.unchangedFiles
+		m.stats.deletedFiles = msg.deletedFiles
+		m.stats.deletedFolders = msg.deletedFolders
+		m.stats.errors = msg.errors
+		m.stats.hashErrors = msg.hashErrors
+		m.stats.mismatches = msg.mismatches
 		m.stats.last = msg.last
 		m.stats.estimatedTotal = msg.estimatedTotal
+		m.stats.discovered = msg.discovered
+		m.stats.totalFinal = msg.totalFinal
 		// Calculate progress percentage
 		if m.stats.estimatedTotal > 0 {
 			m.stats.progress = float64(m.stats.files) / float64(m.stats.estimatedTotal) * 100
@@ -639,15 +881,34 @@ func (m model) updateScan(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 	case doneMsg:
 		m.state = stateDone
-		m.err = msg.err
-		return m, tea.Quit
+		m.finished = time.Now()
+		m.stopping = false
+		m.scanErrors = msg.scanErrors
+		if errors.Is(msg.err, context.Canceled) {
+			m.cancelled = true
+		} else {
+			m.err = msg.err
+		}
+		if m.cancel != nil {
+			m.cancel()
+			m.cancel = nil
+		}
+		return m, nil
 	case tea.WindowSizeMsg:
 		m.windowSize = msg
 		return m, nil
 	case tea.KeyMsg:
 		switch msg.String() {
-		case "q", "esc", "ctrl+c":
-			// just quit; WAL ensures db integrity
+		case "q", "esc":
+			// Ask the scanner to stop; it commits the current batch and
+			// reports back with doneMsg so we can show what was persisted.
+			if m.cancel != nil && !m.stopping {
+				m.cancel()
+				m.stopping = true
+			}
+			return m, nil
+		case "ctrl+c":
+			// force quit; WAL ensures db integrity
 			return m, tea.Quit
 		case "?", "h", "F1":
 			// Show help
@@ -659,6 +920,92 @@ func (m model) updateScan(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m model) updateDone(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.windowSize = msg
+		return m, nil
+	case purgeMsg:
+		if msg.err != nil {
+			m.notice = "Purge failed: " + msg.err.Error()
+		} else {
+			m.notice = fmt.Sprintf("Purged %d deleted files and %d deleted folders", msg.files, msg.folders)
+		}
+		return m, nil
+	case purgePendingMsg:
+		switch {
+		case msg.err != nil:
+			m.notice = "Purge failed: " + msg.err.Error()
+		case msg.files == 0 && msg.folders == 0:
+			m.notice = "No deleted rows to purge"
+		default:
+			m.askPurge = true
+			m.notice = fmt.Sprintf("Purge %d deleted files / %d deleted folders for good? y/n", msg.files, msg.folders)
+		}
+		return m, nil
+	case exportMsg:
+		if msg.err != nil {
+			m.notice = "Export failed: " + msg.err.Error()
+		} else {
+			m.notice = fmt.Sprintf("Exported %d files to %s", msg.rows, msg.path)
+		}
+		return m, nil
+	case tea.KeyMsg:
+		dbPath := m.dbPath
+		if m.askPurge {
+			// Only y purges; any other key, including the ones that would
+			// otherwise quit, just dismisses the question
+			m.askPurge = false
+			if msg.String() != "y" {
+				m.notice = "Purge cancelled"
+				return m, nil
+			}
+			m.notice = "Purging..."
+			return m, func() tea.Msg {
+				files, folders, err := purgeDeleted(dbPath)
+				return purgeMsg{files: files, folders: folders, err: err}
+			}
+		}
+		switch msg.String() {
+		case "p":
+			return m, func() tea.Msg {
+				files, folders, err := countDeleted(dbPath)
+				return purgePendingMsg{files: files, folders: folders, err: err}
+			}
+		case "/":
+			m.state = stateSearch
+			m.search = newSearchModel(m.dbPath, stateDone)
+			return m, m.scheduleSearch()
+		case "d":
+			ctx, cancel := context.WithCancel(context.Background())
+			m.state = stateDupes
+			m.dupes = dupesModel{byFolder: m.dupes.byFolder, cancel: cancel}
+			return m, openDupes(ctx, m.dbPath, m.sender.Send)
+		case "v":
+			ctx, cancel := context.WithCancel(context.Background())
+			m.state = stateVerify
+			m.verify = verifyModel{cancel: cancel}
+			return m, startVerify(ctx, m.dbPath, m.sender.Send)
+		case "b":
+			m.state = stateExplorer
+			m.explorer = explorerModel{sortBy: m.explorer.sortBy, loading: true}
+			return m, openExplorer(m.dbPath, m.stats.runID)
+		case "e", "x":
+			name := "catalog.csv"
+			if msg.String() == "x" {
+				name = "catalog.xlsx"
+			}
+			return m, func() tea.Msg {
+				path := filepath.Join(filepath.Dir(dbPath), name)
+				rows, err := exportCatalogFile(dbPath, path)
+				return exportMsg{path: path, rows: rows, err: err}
+			}
+		}
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
 // Helper methods
 func (m model) loadBrowserEntries() tea.Cmd {
 	return func() tea.Msg {
@@ -709,6 +1056,14 @@ func (m model) View() string {
 		return m.viewDone()
 	case stateHelp:
 		return m.viewHelp()
+	case stateExplorer:
+		return m.viewExplorer()
+	case stateSearch:
+		return m.viewSearch()
+	case stateDupes:
+		return m.viewDupes()
+	case stateVerify:
+		return m.viewVerify()
 	default:
 		return ""
 	}
@@ -772,6 +1127,11 @@ func (m model) viewForm() string {
 
 	// Extension field (no validation needed)
 	fmt.Fprintf(&formContent, "%s%s\n", labelStyle.Render(m.form.ext.Prompt), m.form.ext.View())
+	fmt.Fprintf(&formContent, "%s%s\n", labelStyle.Render(m.form.exclude.Prompt), m.form.exclude.View())
+	fmt.Fprintf(&formContent, "%s%s\n", labelStyle.Render(m.form.size.Prompt), m.form.size.View())
+	fmt.Fprintf(&formContent, "%s%s\n", labelStyle.Render(m.form.modified.Prompt), m.form.modified.View())
+	fmt.Fprintf(&formContent, "%s%s\n", labelStyle.Render(m.form.workers.Prompt), m.form.workers.View())
+	fmt.Fprintf(&formContent, "%s%s\n", labelStyle.Render(m.form.algos.Prompt), m.form.algos.View())
 
 	// Hash toggle with beautiful styling
 	hashMark := "off"
@@ -785,6 +1145,44 @@ func (m model) viewForm() string {
 		lipgloss.NewStyle().Foreground(hashColor).Bold(true).Render(hashMark),
 		lipgloss.NewStyle().Foreground(lipgloss.Color("#c4b5fd")).Render("(SPACE toggles)"))
 
+	incrMark := "off"
+	incrColor := lipgloss.Color("#ef4444")
+	if m.form.incrOn {
+		incrMark = "on"
+		incrColor = lipgloss.Color("#22c55e")
+	}
+	fmt.Fprintf(&formContent, "%s %s  %s\n",
+		labelStyle.Render("Incremental:"),
+		lipgloss.NewStyle().Foreground(incrColor).Bold(true).Render(incrMark),
+		lipgloss.NewStyle().Foreground(lipgloss.Color("#c4b5fd")).Render("(Ctrl+T toggles, skips unchanged files)"))
+
+	sniffMark := "off"
+	sniffColor := lipgloss.Color("#ef4444")
+	if m.form.sniffOn {
+		sniffMark = "on"
+		sniffColor = lipgloss.Color("#22c55e")
+	}
+	fmt.Fprintf(&formContent, "%s %s  %s\n",
+		labelStyle.Render("Sniff content:"),
+		lipgloss.NewStyle().Foreground(sniffColor).Bold(true).Render(sniffMark),
+		lipgloss.NewStyle().Foreground(lipgloss.Color("#c4b5fd")).Render("(Ctrl+O toggles, finds files whose content contradicts their extension)"))
+
+	resumeMark := "off"
+	resumeColor := lipgloss.Color("#ef4444")
+	if m.form.resume {
+		resumeMark = "on"
+		resumeColor = lipgloss.Color("#22c55e")
+	}
+	fmt.Fprintf(&formContent, "%s %s  %s\n",
+		labelStyle.Render("Resume last run:"),
+		lipgloss.NewStyle().Foreground(resumeColor).Bold(true).Render(resumeMark),
+		lipgloss.NewStyle().Foreground(lipgloss.Color("#c4b5fd")).Render("(Ctrl+R toggles, continues an interrupted scan)"))
+
+	fmt.Fprintf(&formContent, "%s %s  %s\n",
+		labelStyle.Render("Symlinks:"),
+		lipgloss.NewStyle().Foreground(lipgloss.Color("#7aa2f7")).Bold(true).Render(m.form.symlinks.String()),
+		lipgloss.NewStyle().Foreground(lipgloss.Color("#c4b5fd")).Render("(Ctrl+L cycles record/ignore/follow)"))
+
 	// Render the form box
 	form := formBox.Render(formContent.String())
 	fmt.Fprintf(&b, "%s\n", form)
@@ -899,12 +1297,13 @@ func (m model) viewForm() string {
 	enterKey := lipgloss.NewStyle().Background(lipgloss.Color("#7c3aed")).Foreground(lipgloss.Color("#f1f5f9")).Padding(0, 1).Bold(true).Render("Enter")
 	tabKey := lipgloss.NewStyle().Background(lipgloss.Color("#10b981")).Foreground(lipgloss.Color("#f1f5f9")).Padding(0, 1).Bold(true).Render("TAB")
 	browseKey := lipgloss.NewStyle().Background(lipgloss.Color("#06b6d4")).Foreground(lipgloss.Color("#f1f5f9")).Padding(0, 1).Bold(true).Render("Ctrl+B")
+	searchKey := lipgloss.NewStyle().Background(lipgloss.Color("#06b6d4")).Foreground(lipgloss.Color("#f1f5f9")).Padding(0, 1).Bold(true).Render("Ctrl+F")
 	numberKey := lipgloss.NewStyle().Background(lipgloss.Color("#f59e0b")).Foreground(lipgloss.Color("#f1f5f9")).Padding(0, 1).Bold(true).Render("1-9")
 	helpKey := lipgloss.NewStyle().Background(lipgloss.Color("#3b82f6")).Foreground(lipgloss.Color("#f1f5f9")).Padding(0, 1).Bold(true).Render("?")
 	quitKey := lipgloss.NewStyle().Background(lipgloss.Color("#ef4444")).Foreground(lipgloss.Color("#f1f5f9")).Padding(0, 1).Bold(true).Render("q")
 
-	keyHelp := fmt.Sprintf("%s start • %s complete • %s browse • %s recent • %s help • %s quit",
-		enterKey, tabKey, browseKey, numberKey, helpKey, quitKey)
+	keyHelp := fmt.Sprintf("%s start • %s complete • %s browse • %s search • %s recent • %s help • %s quit",
+		enterKey, tabKey, browseKey, searchKey, numberKey, helpKey, quitKey)
 
 	footer := footerBox.Render(keyHelp)
 	fmt.Fprintf(&b, "%s\n", footer)
@@ -1005,8 +1404,13 @@ func (m model) viewHelp() string {
 	fmt.Fprintf(&b, "%s\n", val.Render("🔸 Setup Form"))
 	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Tab/↓"), lbl.Render("Move to next field"))
 	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Shift+Tab/↑"), lbl.Render("Move to previous field"))
-	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Space"), lbl.Render("Toggle hash calculation on/off"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Space"), lbl.Render("Toggle hash calculation on/off (not while typing a filter)"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Ctrl+T"), lbl.Render("Toggle incremental rescan (skip unchanged files)"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Ctrl+O"), lbl.Render("Toggle content sniffing (read file headers to check extensions)"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Ctrl+R"), lbl.Render("Toggle resuming the last interrupted scan of the root"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Ctrl+L"), lbl.Render("Cycle symlink handling: record, ignore or follow"))
 	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Ctrl+B"), lbl.Render("Open directory browser"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Ctrl+F"), lbl.Render("Search the catalog in the output dir"))
 	fmt.Fprintf(&b, "  %s %s\n\n", acc.Render("Enter"), lbl.Render("Start cataloging"))
 
 	// Browser screen shortcuts
@@ -1018,21 +1422,60 @@ func (m model) viewHelp() string {
 
 	// Scanning screen shortcuts
 	fmt.Fprintf(&b, "%s\n", val.Render("🔸 Scanning Progress"))
-	fmt.Fprintf(&b, "  %s %s\n", acc.Render("q/ESC"), lbl.Render("Stop scanning (safe - database preserved)"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("q/ESC"), lbl.Render("Stop scanning (commits current batch, shows summary)"))
 	fmt.Fprintf(&b, "  %s %s\n\n", acc.Render("Ctrl+C"), lbl.Render("Force stop"))
 
+	// Explorer screen shortcuts
+	fmt.Fprintf(&b, "%s\n", val.Render("🔸 Catalog Explorer (b on the results screen)"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("↑/↓ or j/k"), lbl.Render("Navigate up/down"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Enter"), lbl.Render("Open selected folder (.. goes up)"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Backspace"), lbl.Render("Go up a folder"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("s"), lbl.Render("Sort by size, date or name"))
+	fmt.Fprintf(&b, "  %s %s\n\n", acc.Render("ESC"), lbl.Render("Return to results"))
+
+	// Search screen shortcuts
+	fmt.Fprintf(&b, "%s\n", val.Render("🔸 Search (Ctrl+F on the form, / on the results screen)"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Typing"), lbl.Render("Filter by name, extensions, size and modified date as you type"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Tab/Shift+Tab"), lbl.Render("Move between search fields"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("↑/↓, PgUp/PgDn"), lbl.Render("Select a result; its full path is shown below the table"))
+	fmt.Fprintf(&b, "  %s %s\n\n", acc.Render("ESC"), lbl.Render("Return to the previous screen"))
+
+	// Duplicates screen shortcuts
+	fmt.Fprintf(&b, "%s\n", val.Render("🔸 Duplicates (d on the results screen)"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("↑/↓ or j/k"), lbl.Render("Select a set; its copies are listed below, oldest (kept) first"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Tab"), lbl.Render("Switch between duplicate sets and wasted space per folder"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("e"), lbl.Render("Export the sets to duplicates.csv next to the database"))
+	fmt.Fprintf(&b, "  %s %s\n\n", acc.Render("ESC"), lbl.Render("Return to results; while hashing, stop first (hashes so far are kept)"))
+
+	// Verify screen shortcuts
+	fmt.Fprintf(&b, "%s\n", val.Render("🔸 Verify (v on the results screen)"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("Status"), lbl.Render("ok, modified, corrupted (same size and mtime, new hash), missing or error"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("↑/↓ or j/k"), lbl.Render("Select a problem to see its details"))
+	fmt.Fprintf(&b, "  %s %s\n\n", acc.Render("ESC"), lbl.Render("Stop verifying (results so far are kept), then return to results"))
+
 	// Usage tips
 	fmt.Fprintf(&b, "%s\n", val.Render("🔸 Usage Tips"))
 	fmt.Fprintf(&b, "  • %s\n", lbl.Render("Use extension filter like: .pdf,.docx,.xlsx"))
+	fmt.Fprintf(&b, "  • %s\n", lbl.Render("Exclude skips matching paths, e.g. node_modules, ~$*, .git/, Forms/"))
+	fmt.Fprintf(&b, "  • %s\n", lbl.Render("Size filter: >10MB, <=1GB or 10MB-1GB (comma-separated terms all apply)"))
+	fmt.Fprintf(&b, "  • %s\n", lbl.Render("Modified: older than 3y, newer than 30d, since/before 2024-01-01 or 2023-01-01..2023-12-31"))
+	fmt.Fprintf(&b, "  • %s\n", lbl.Render("A .spcatalogignore file adds gitignore-style rules for its folder"))
 	fmt.Fprintf(&b, "  • %s\n", lbl.Render("Hash calculation adds file integrity checking but takes longer"))
+	fmt.Fprintf(&b, "  • %s\n", lbl.Render("Files that fail to hash get no checksum; an incremental rescan with hashing retries them"))
+	fmt.Fprintf(&b, "  • %s\n", lbl.Render("Duplicates only hashes files that share a size, so it works without hashing too"))
+	fmt.Fprintf(&b, "  • %s\n", lbl.Render("Hash workers sets how many files are hashed in parallel"))
+	fmt.Fprintf(&b, "  • %s\n", lbl.Render("Hash algorithms: sha256, sha1, md5, quickxor or all; quickxor matches OneDrive/SharePoint's quickXorHash"))
 	fmt.Fprintf(&b, "  • %s\n", lbl.Render("Output database is SQLite - query with any SQLite tool"))
 	fmt.Fprintf(&b, "  • %s\n", lbl.Render("Stopping scan early preserves already cataloged data"))
 	fmt.Fprintf(&b, "  • %s\n\n", lbl.Render("Database uses WAL mode for performance and safety"))
 
 	// Database schema
 	fmt.Fprintf(&b, "%s\n", val.Render("🔸 Database Schema"))
-	fmt.Fprintf(&b, "  %s %s\n", acc.Render("files:"), lbl.Render("abs_path, folder_path, name, ext, size, mtime_utc, mime, sha256"))
-	fmt.Fprintf(&b, "  %s %s\n\n", acc.Render("folders:"), lbl.Render("path, parent_path, mtime_utc"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("files:"), lbl.Render("abs_path, folder_path, name, ext, size, mtime_utc, mime, sha256, sha1, md5, quickxor, hash_status, hash_error, sniffed_mime, seen_run, deleted_at, deleted_run, entry_type, link_target"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("folders:"), lbl.Render("path, parent_path, mtime_utc, seen_run, deleted_at, deleted_run"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("scan_runs:"), lbl.Render("id, root, ext_filter, hash, options, started_utc, finished_utc, duration_ms, files, folders, bytes, status, error, errors, checkpoint_path, checkpoint_utc"))
+	fmt.Fprintf(&b, "  %s %s\n", acc.Render("scan_errors:"), lbl.Render("id, run_id, path, op, error, at_utc"))
+	fmt.Fprintf(&b, "  %s %s\n\n", acc.Render("verify_results:"), lbl.Render("id, verify_run, abs_path, status, expected_sha256, actual_sha256, size, mtime_utc, error, checked_utc"))
 
 	// Example queries
 	fmt.Fprintf(&b, "%s\n", val.Render("🔸 Example SQLite Queries"))
@@ -1094,15 +1537,17 @@ func (m model) viewScan() string {
 	// Create individual stat cards
 	filesCard := lipgloss.NewStyle().Foreground(lipgloss.Color("#6ee7b7")).Bold(true).Render(fmt.Sprintf("%d\nFiles", m.stats.files))
 	foldersCard := lipgloss.NewStyle().Foreground(lipgloss.Color("#7dd3fc")).Bold(true).Render(fmt.Sprintf("%d\nFolders", m.stats.folders))
+	bytesCard := lipgloss.NewStyle().Foreground(lipgloss.Color("#c4b5fd")).Bold(true).Render(fmt.Sprintf("%s\nData", formatSize(m.stats.bytes)))
 	speedCard := lipgloss.NewStyle().Foreground(lipgloss.Color("#fbbf24")).Bold(true).Render(fmt.Sprintf("%s/s\nSpeed", speed))
 	elapsedCard := lipgloss.NewStyle().Foreground(lipgloss.Color("#f472b6")).Bold(true).Render(fmt.Sprintf("%s\nElapsed", elapsedStr))
 
 	// Layout stats in a row
 	statsRow := lipgloss.JoinHorizontal(lipgloss.Top,
-		lipgloss.NewStyle().Width(contentWidth/4).Align(lipgloss.Center).Render(filesCard),
-		lipgloss.NewStyle().Width(contentWidth/4).Align(lipgloss.Center).Render(foldersCard),
-		lipgloss.NewStyle().Width(contentWidth/4).Align(lipgloss.Center).Render(speedCard),
-		lipgloss.NewStyle().Width(contentWidth/4).Align(lipgloss.Center).Render(elapsedCard))
+		lipgloss.NewStyle().Width(contentWidth/5).Align(lipgloss.Center).Render(filesCard),
+		lipgloss.NewStyle().Width(contentWidth/5).Align(lipgloss.Center).Render(foldersCard),
+		lipgloss.NewStyle().Width(contentWidth/5).Align(lipgloss.Center).Render(bytesCard),
+		lipgloss.NewStyle().Width(contentWidth/5).Align(lipgloss.Center).Render(speedCard),
+		lipgloss.NewStyle().Width(contentWidth/5).Align(lipgloss.Center).Render(elapsedCard))
 
 	stats := statsBox.Render(statsRow)
 	fmt.Fprintf(&b, "%s\n", stats)
@@ -1122,6 +1567,19 @@ func (m model) viewScan() string {
 			lipgloss.NewStyle().Foreground(lipgloss.Color("#94a3b8")).Render(m.stats.last))
 	}
 
+	if m.stats.resumedFrom != "" {
+		fmt.Fprintf(&b, "%s %s\n",
+			lipgloss.NewStyle().Foreground(lipgloss.Color("#94a3b8")).Bold(true).Render("↻ Resumed after:"),
+			lipgloss.NewStyle().Foreground(lipgloss.Color("#94a3b8")).Render(m.stats.resumedFrom))
+	}
+
+	// Unreadable paths, counted live and recorded in scan_errors
+	if m.stats.errors > 0 {
+		fmt.Fprintf(&b, "%s %s\n",
+			lipgloss.NewStyle().Foreground(lipgloss.Color("#ef4444")).Bold(true).Render("⚠ Errors:"),
+			lipgloss.NewStyle().Foreground(lipgloss.Color("#fca5a5")).Render(fmt.Sprintf("%d paths could not be read", m.stats.errors)))
+	}
+
 	// Progress bar with percentage and estimated completion
 	progressWidth := m.getProgressBarWidth()
 	var progressBar string
@@ -1137,15 +1595,15 @@ func (m model) viewScan() string {
 		filledBar := lipgloss.NewStyle().Foreground(lipgloss.Color("#10b981")).Render(strings.Repeat("█", filled))
 		emptyBar := lipgloss.NewStyle().Foreground(lipgloss.Color("#334155")).Render(strings.Repeat("░", progressWidth-filled))
 		progressBar = filledBar + emptyBar
-		progressText = fmt.Sprintf("%.1f%% (%d/%d files)", progress, m.stats.files, m.stats.estimatedTotal)
+		total := fmt.Sprintf("%d", m.stats.estimatedTotal)
+		if !m.stats.totalFinal {
+			total = "~" + total // previous run's count
+		}
+		progressText = fmt.Sprintf("%.1f%% (%d/%s files)", progress, m.stats.files, total)
 
 		// Calculate time remaining
-		if elapsed > 0 && progress > 0 {
-			totalEstimatedTime := elapsed.Seconds() * 100 / progress
-			remainingTime := time.Duration(totalEstimatedTime-elapsed.Seconds()) * time.Second
-			if remainingTime > 0 {
-				progressText += fmt.Sprintf(" • ~%s remaining", remainingTime.Round(time.Second))
-			}
+		if remaining := remainingTime(m.stats.files, m.stats.estimatedTotal, elapsed); remaining > 0 {
+			progressText += fmt.Sprintf(" • ~%s remaining", remaining.Round(time.Second))
 		}
 	} else {
 		// Show indeterminate progress
@@ -1154,6 +1612,9 @@ func (m model) viewScan() string {
 		emptyBar := lipgloss.NewStyle().Foreground(lipgloss.Color("#334155")).Render(strings.Repeat("░", progressWidth-filled))
 		progressBar = filledBar + emptyBar
 		progressText = "Scanning..."
+		if m.stats.discovered > 0 {
+			progressText = fmt.Sprintf("Scanning... %d files found so far", m.stats.discovered)
+		}
 	}
 
 	fmt.Fprintf(&b, "\n%s %s\n",
@@ -1173,16 +1634,66 @@ func (m model) viewScan() string {
 
 	// Help text with beautiful colors
 	helpText := lipgloss.NewStyle().Foreground(lipgloss.Color("#94a3b8")).Render("Press q/ESC to stop (safe)")
+	if m.stopping {
+		helpText = lipgloss.NewStyle().Foreground(lipgloss.Color("#fbbf24")).Bold(true).Render("Stopping… committing the current batch")
+	}
 	fmt.Fprintf(&b, "\n%s\n", helpText)
 
 	return b.String()
 }
 
+// resultRow is one line of the results table, shared by the done screen
+// and the HTML report.
+type resultRow struct {
+	label, value string
+	alert        bool // needs attention, e.g. errors
+}
+
+// resultRows summarizes a finished scan. incremental adds the new, changed
+// and unchanged breakdown.
+func (s stats) resultRows(elapsed time.Duration, incremental bool) []resultRow {
+	var avgSpeed float64
+	if elapsed > 0 {
+		avgSpeed = float64(s.files) / elapsed.Seconds()
+	}
+	rows := []resultRow{
+		{label: "Files Cataloged", value: fmt.Sprintf("%d", s.files)},
+		{label: "Folders Scanned", value: fmt.Sprintf("%d", s.folders)},
+		{label: "Data Cataloged", value: formatSize(s.bytes)},
+	}
+	if incremental {
+		rows = append(rows,
+			resultRow{label: "New Files", value: fmt.Sprintf("%d", s.newFiles)},
+			resultRow{label: "Changed Files", value: fmt.Sprintf("%d", s.changedFiles)},
+			resultRow{label: "Unchanged Files", value: fmt.Sprintf("%d", s.unchangedFiles)})
+	}
+	if s.errors > 0 {
+		rows = append(rows, resultRow{label: "Errors", value: fmt.Sprintf("%d", s.errors), alert: true})
+	}
+	if s.hashErrors > 0 {
+		rows = append(rows, resultRow{label: "Hash Failures", value: fmt.Sprintf("%d", s.hashErrors), alert: true})
+	}
+	if s.mismatches > 0 {
+		rows = append(rows, resultRow{label: "Content Mismatches", value: fmt.Sprintf("%d", s.mismatches), alert: true})
+	}
+	if s.deletedFiles > 0 || s.deletedFolders > 0 {
+		rows = append(rows, resultRow{label: "Newly Deleted", value: fmt.Sprintf("%d files, %d folders", s.deletedFiles, s.deletedFolders)})
+	}
+	return append(rows,
+		resultRow{label: "Time Elapsed", value: elapsed.Round(time.Second).String()},
+		resultRow{label: "Average Speed", value: fmt.Sprintf("%.1f files/sec", avgSpeed)})
+}
+
 func (m model) viewDone() string {
 	var b strings.Builder
 
 	// Header
-	if m.err == nil {
+	if m.cancelled {
+		fmt.Fprintf(&b, "%s %s\n\n",
+			lipgloss.NewStyle().Foreground(lipgloss.Color("#f59e0b")).Bold(true).Render("■"),
+			lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#f59e0b")).Render("Scan Cancelled"))
+		fmt.Fprintf(&b, "%s\n\n", lbl.Render("Everything counted below was committed to the database before stopping."))
+	} else if m.err == nil {
 		fmt.Fprintf(&b, "%s %s\n\n",
 			ok.Render("✓"),
 			lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#22c55e")).Render("Cataloging Complete!"))
@@ -1194,7 +1705,7 @@ func (m model) viewDone() string {
 	}
 
 	// Summary statistics
-	elapsed := time.Since(m.start)
+	elapsed := m.finished.Sub(m.start)
 	var avgSpeed float64
 	if elapsed > 0 {
 		avgSpeed = float64(m.stats.files) / elapsed.Seconds()
@@ -1205,13 +1716,45 @@ func (m model) viewDone() string {
 	fmt.Fprintf(&b, "│ %-55s │\n", val.Render("CATALOGING RESULTS"))
 	fmt.Fprintf(&b, "├─────────────────────────────────────────────────────────┤\n")
 	fmt.Fprintf(&b, "│ %-20s │ %-32s │\n", lbl.Render("Database Location:"), acc.Render(m.dbPath))
+	if m.stats.runID != "" {
+		runLabel := m.stats.runID
+		if m.stats.resumedFrom != "" {
+			runLabel += " (resumed)"
+		}
+		fmt.Fprintf(&b, "│ %-20s │ %-32s │\n", lbl.Render("Run ID:"), acc.Render(runLabel))
+	}
 	fmt.Fprintf(&b, "├─────────────────────────────────────────────────────────┤\n")
-	fmt.Fprintf(&b, "│ %-20s │ %-32s │\n", lbl.Render("Files Cataloged:"), val.Render(fmt.Sprintf("%d", m.stats.files)))
-	fmt.Fprintf(&b, "│ %-20s │ %-32s │\n", lbl.Render("Folders Scanned:"), val.Render(fmt.Sprintf("%d", m.stats.folders)))
-	fmt.Fprintf(&b, "│ %-20s │ %-32s │\n", lbl.Render("Time Elapsed:"), val.Render(elapsed.Round(time.Second).String()))
-	fmt.Fprintf(&b, "│ %-20s │ %-32s │\n", lbl.Render("Average Speed:"), val.Render(fmt.Sprintf("%.1f files/sec", avgSpeed)))
+	for _, r := range m.stats.resultRows(elapsed, m.form.incrOn) {
+		style := val
+		if r.alert {
+			style = bad
+		}
+		fmt.Fprintf(&b, "│ %-20s │ %-32s │\n", lbl.Render(r.label+":"), style.Render(r.value))
+	}
 	fmt.Fprintf(&b, "└─────────────────────────────────────────────────────────┘\n\n")
 
+	// Paths that could not be read
+	if len(m.scanErrors) > 0 {
+		fmt.Fprintf(&b, "%s\n", bad.Render("Scan Errors:"))
+		for _, e := range m.scanErrors {
+			fmt.Fprintf(&b, "• %s %s %s\n", acc.Render(e.op), m.wrapText(e.path, m.getTableWidth()), lbl.Render(e.err))
+		}
+		if more := m.stats.errors - int64(len(m.scanErrors)); more > 0 {
+			fmt.Fprintf(&b, "  %s\n", lbl.Render(fmt.Sprintf("... and %d more", more)))
+		}
+		fmt.Fprintf(&b, "  %s\n", lbl.Render(fmt.Sprintf("SELECT * FROM scan_errors WHERE run_id = '%s';", m.stats.runID)))
+		if m.stats.hashErrors > 0 {
+			fmt.Fprintf(&b, "  %s\n", lbl.Render("Files that failed to hash have no checksum; an incremental scan with hashing retries just those"))
+		}
+		fmt.Fprintln(&b)
+	}
+
+	// Files whose content contradicts their extension
+	if m.stats.mismatches > 0 {
+		fmt.Fprintf(&b, "%s\n", bad.Render(fmt.Sprintf("%d files have content that contradicts their extension", m.stats.mismatches)))
+		fmt.Fprintf(&b, "  %s\n\n", lbl.Render(fmt.Sprintf("spcatalog mismatches --db %s", m.dbPath)))
+	}
+
 	// Performance visualization
 	if m.stats.files > 0 {
 		fmt.Fprintf(&b, "%s\n", val.Render("Performance Breakdown:"))
@@ -1246,283 +1789,16 @@ func (m model) viewDone() string {
 	fmt.Fprintf(&b, "• %s\n", lbl.Render("Find files: SELECT * FROM files WHERE name LIKE '%.pdf';"))
 	fmt.Fprintf(&b, "• %s\n", lbl.Render("Analyze folders: SELECT COUNT(*) FROM files GROUP BY folder_path;"))
 	fmt.Fprintf(&b, "• %s\n", lbl.Render("View schema: .schema"))
+	fmt.Fprintf(&b, "• %s\n", lbl.Render("Deleted rows: SELECT * FROM files WHERE deleted_at IS NOT NULL;"))
+	fmt.Fprintf(&b, "• %s\n", lbl.Render("Scan history: SELECT * FROM scan_runs ORDER BY started_utc DESC;"))
+	fmt.Fprintf(&b, "• %s\n", lbl.Render("Open in Excel: press x for a workbook, or spcatalog export --db "+m.dbPath+" --out catalog.xlsx"))
 
-	fmt.Fprintf(&b, "\n%s\n", lbl.Render("Press any key to exit"))
-
-	return b.String()
-}
-
-// ---------- scanning & DB ----------
-
-func runScan(root, dbPath string, extFilter map[string]struct{}, hash bool) tea.Cmd {
-	return func() tea.Msg {
-		// First, estimate total files
-		estimatedTotal := estimateFileCount(root, extFilter)
-
-		err := scanAndPersist(root, dbPath, extFilter, hash, estimatedTotal, func(files, folders int64, last string, estimated int64) tea.Msg {
-			return progressMsg{files: files, folders: folders, last: last, estimatedTotal: estimated}
-		})
-		return doneMsg{err: err}
-	}
-}
-
-func estimateFileCount(root string, extFilter map[string]struct{}) int64 {
-	var count int64
-
-	// Quick estimation by walking the directory tree
-	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return nil // Continue on errors
-		}
-
-		if !d.IsDir() {
-			ext := strings.ToLower(filepath.Ext(path))
-			if len(extFilter) > 0 {
-				if _, ok := extFilter[ext]; ok {
-					count++
-				}
-			} else {
-				count++
-			}
-		}
-		return nil
-	})
-
-	return count
-}
-
-func scanAndPersist(root, dbPath string, extFilter map[string]struct{}, hash bool, estimatedTotal int64, progress func(int64, int64, string, int64) tea.Msg) error {
-	db, err := sql.Open("sqlite", dbPath)
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	if err := initSchema(db); err != nil {
-		return err
-	}
-	if _, err := db.Exec(`PRAGMA journal_mode=WAL; PRAGMA synchronous=NORMAL; PRAGMA temp_store=MEMORY;`); err != nil {
-		return err
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
-
-	folderStmt, err := tx.Prepare(`
-		INSERT INTO folders(path, parent_path, mtime_utc)
-		VALUES(?, ?, ?)
-		ON CONFLICT(path) DO UPDATE SET mtime_utc=excluded.mtime_utc
-	`)
-	if err != nil {
-		return err
-	}
-	defer folderStmt.Close()
-
-	fileStmt, err := tx.Prepare(`
-		INSERT INTO files(abs_path, folder_path, name, ext, size, mtime_utc, mime, sha256)
-		VALUES(?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(abs_path) DO UPDATE SET
-		  size=excluded.size, mtime_utc=excluded.mtime_utc, mime=excluded.mime,
-		  sha256=COALESCE(excluded.sha256, files.sha256)
-	`)
-	if err != nil {
-		return err
-	}
-	defer fileStmt.Close()
-
-	var files, dirs int64
-	batch := 0
-	root = filepath.Clean(root)
-
-	errWalk := filepath.WalkDir(root, func(p string, d os.DirEntry, walkErr error) error {
-		if walkErr != nil {
-			return nil
-		}
-		info, err := d.Info()
-		if err != nil {
-			return nil
-		}
-
-		if d.IsDir() {
-			dirs++
-			parent := filepath.Dir(p)
-			if parent == p {
-				parent = ""
-			}
-			mtime := info.ModTime().UTC().Format(time.RFC3339)
-			if _, err := folderStmt.Exec(p, parent, mtime); err != nil {
-				return err
-			}
-			batch++
-			if batch >= 1000 {
-				if err := tx.Commit(); err != nil {
-					return err
-				}
-				progress(files, dirs, p, estimatedTotal)
-				tx, err = db.Begin()
-				if err != nil {
-					return err
-				}
-				folderStmt, err = tx.Prepare(`
-					INSERT INTO folders(path, parent_path, mtime_utc)
-					VALUES(?, ?, ?)
-					ON CONFLICT(path) DO UPDATE SET mtime_utc=excluded.mtime_utc
-				`)
-				if err != nil {
-					return err
-				}
-				fileStmt, err = tx.Prepare(`
-					INSERT INTO files(abs_path, folder_path, name, ext, size, mtime_utc, mime, sha256)
-					VALUES(?, ?, ?, ?, ?, ?, ?, ?)
-					ON CONFLICT(abs_path) DO UPDATE SET
-					  size=excluded.size, mtime_utc=excluded.mtime_utc, mime=excluded.mime,
-					  sha256=COALESCE(excluded.sha256, files.sha256)
-				`)
-				if err != nil {
-					return err
-				}
-				batch = 0
-			}
-			return nil
-		}
-
-		ext := strings.ToLower(filepath.Ext(p))
-		if len(extFilter) > 0 {
-			if _, ok := extFilter[ext]; !ok {
-				return nil
-			}
-		}
-
-		files++
-		dir := filepath.Dir(p)
-		name := filepath.Base(p)
-		size := info.Size()
-		mtime := info.ModTime().UTC().Format(time.RFC3339)
-		mimetype := detectMIME(ext)
-
-		var sum *string
-		if hash {
-			s := hashFile(p)
-			if s != "" {
-				sum = &s
-			}
-		}
-
-		if _, err := fileStmt.Exec(p, dir, name, ext, size, mtime, mimetype, sum); err != nil {
-			return err
-		}
-
-		batch++
-		if batch >= 1000 {
-			if err := tx.Commit(); err != nil {
-				return err
-			}
-			progress(files, dirs, p, estimatedTotal)
-			tx, err = db.Begin()
-			if err != nil {
-				return err
-			}
-			folderStmt, err = tx.Prepare(`
-				INSERT INTO folders(path, parent_path, mtime_utc)
-				VALUES(?, ?, ?)
-				ON CONFLICT(path) DO UPDATE SET mtime_utc=excluded.mtime_utc
-			`)
-			if err != nil {
-				return err
-			}
-			fileStmt, err = tx.Prepare(`
-				INSERT INTO files(abs_path, folder_path, name, ext, size, mtime_utc, mime, sha256)
-				VALUES(?, ?, ?, ?, ?, ?, ?, ?)
-				ON CONFLICT(abs_path) DO UPDATE SET
-				  size=excluded.size, mtime_utc=excluded.mtime_utc, mime=excluded.mime,
-				  sha256=COALESCE(excluded.sha256, files.sha256)
-			`)
-			if err != nil {
-				return err
-			}
-			batch = 0
-		}
-
-		return nil
-	})
-	if errWalk != nil {
-		_ = tx.Rollback()
-		return errWalk
-	}
-	if err := tx.Commit(); err != nil {
-		return err
-	}
-
-	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_files_ext ON files(ext);`)
-	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_files_folder ON files(folder_path);`)
-	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_files_mtime ON files(mtime_utc);`)
-
-	progress(files, dirs, "", estimatedTotal)
-	return nil
-}
-
-func initSchema(db *sql.DB) error {
-	ddl := `
-CREATE TABLE IF NOT EXISTS folders (
-	path TEXT PRIMARY KEY,
-	parent_path TEXT,
-	mtime_utc TEXT
-);
-CREATE TABLE IF NOT EXISTS files (
-	abs_path    TEXT PRIMARY KEY,
-	folder_path TEXT NOT NULL,
-	name        TEXT NOT NULL,
-	ext         TEXT,
-	size        INTEGER,
-	mtime_utc   TEXT,
-	mime        TEXT,
-	sha256      TEXT
-);
-`
-	_, err := db.Exec(ddl)
-	return err
-}
-
-func parseExtSet(s string) map[string]struct{} {
-	m := map[string]struct{}{}
-	if s == "" {
-		return m
-	}
-	for _, e := range strings.Split(s, ",") {
-		e = strings.ToLower(strings.TrimSpace(e))
-		if e == "" {
-			continue
-		}
-		if !strings.HasPrefix(e, ".") {
-			e = "." + e
-		}
-		m[e] = struct{}{}
-	}
-	return m
-}
-
-func detectMIME(ext string) string {
-	if ext == ".msg" {
-		return "application/vnd.ms-outlook"
-	}
-	mt := mime.TypeByExtension(ext)
-	if mt != "" {
-		return mt
+	if m.notice != "" {
+		fmt.Fprintf(&b, "\n%s\n", acc.Render(m.notice))
 	}
-	return "application/octet-stream"
-}
+	fmt.Fprintf(&b, "\n%s\n", lbl.Render("Press b to browse • / to search • d for duplicates • v to verify hashes • e to export CSV • x for XLSX • p to purge deleted rows • any other key to exit"))
 
-func hashFile(path string) string {
-	f, err := os.Open(path)
-	if err != nil {
-		return ""
-	}
-	defer f.Close()
-	h := sha256.New()
-	_, _ = io.Copy(h, f)
-	return fmt.Sprintf("%x", h.Sum(nil))
+	return b.String()
 }
 
 // Speed formatter
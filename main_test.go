@@ -1,10 +1,25 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	_ "modernc.org/sqlite"
@@ -133,7 +148,10 @@ func TestHashFile(t *testing.T) {
 	}
 
 	// Hash the file
-	hash := hashFile(testFile)
+	hash, err := hashFile(testFile)
+	if err != nil {
+		t.Fatalf("hashFile(%q) failed: %v", testFile, err)
+	}
 
 	// Expected SHA256 hash of "Hello, World!"
 	expected := "dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f"
@@ -144,9 +162,169 @@ func TestHashFile(t *testing.T) {
 
 	// Test non-existent file
 	nonExistentFile := filepath.Join(tmpDir, "nonexistent.txt")
-	emptyHash := hashFile(nonExistentFile)
-	if emptyHash != "" {
-		t.Errorf("hashFile(%q) = %q, want empty string", nonExistentFile, emptyHash)
+	if emptyHash, err := hashFile(nonExistentFile); err == nil || emptyHash != "" {
+		t.Errorf("hashFile(%q) = %q, %v; want an error", nonExistentFile, emptyHash, err)
+	}
+
+	// A read that fails after opening must not yield the hash of nothing
+	if dirHash, err := hashFile(tmpDir); err == nil || dirHash != "" {
+		t.Errorf("hashFile(%q) = %q, %v; want a read error", tmpDir, dirHash, err)
+	}
+}
+
+func TestHashFileWith(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(testFile, []byte("Hello, World!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sums, err := hashFileWith(testFile, []string{"sha256", "sha1", "md5", "quickxor"})
+	if err != nil {
+		t.Fatalf("hashFileWith() failed: %v", err)
+	}
+	want := map[string]string{
+		"sha256":   "dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f",
+		"sha1":     "0a0a9f2a6772942557ab5355d76af442f8f65e01",
+		"md5":      "65a8e27d8879283831b664bd8b7f0ad4",
+		"quickxor": quickXorReference([]byte("Hello, World!")),
+	}
+	if !reflect.DeepEqual(sums, want) {
+		t.Errorf("hashFileWith() = %v, want %v", sums, want)
+	}
+
+	if _, err := hashFileWith(testFile, []string{"crc32"}); err == nil {
+		t.Error("hashFileWith() with an unknown algorithm succeeded")
+	}
+}
+
+func TestQuickXorHash(t *testing.T) {
+	sum := func(b []byte) string {
+		h := newQuickXorHash()
+		h.Write(b)
+		return base64.StdEncoding.EncodeToString(h.Sum(nil))
+	}
+	if got := sum(nil); got != "AAAAAAAAAAAAAAAAAAAAAAAAAAA=" {
+		t.Errorf("empty input = %q, want all zeros", got)
+	}
+	if got := sum([]byte{0x4A}); got != "SgAAAAAAAAAAAAAAAQAAAAAAAAA=" {
+		t.Errorf("one byte = %q, want the byte in bit 0 and length 1", got)
+	}
+
+	// Long enough to wrap the 160-bit state many times, written in uneven
+	// chunks to cover a split between Writes
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i*37 + 11)
+	}
+	h := newQuickXorHash()
+	for rest := data; len(rest) > 0; {
+		n := min(len(rest), 7)
+		h.Write(rest[:n])
+		rest = rest[n:]
+	}
+	if got, want := base64.StdEncoding.EncodeToString(h.Sum(nil)), quickXorReference(data); got != want {
+		t.Errorf("chunked input = %q, want %q", got, want)
+	}
+	h.Reset()
+	if got := base64.StdEncoding.EncodeToString(h.Sum(nil)); got != "AAAAAAAAAAAAAAAAAAAAAAAAAAA=" {
+		t.Errorf("after Reset = %q, want all zeros", got)
+	}
+}
+
+// quickXorReference computes QuickXorHash straight from its definition: a
+// 160-bit rotate-and-XOR over the input, with the length XORed into the top
+// 64 bits.
+func quickXorReference(data []byte) string {
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 160), big.NewInt(1))
+	state := new(big.Int)
+	for k, c := range data {
+		shift := uint(k * 11 % 160)
+		v := new(big.Int).Lsh(big.NewInt(int64(c)), shift)
+		wrapped := new(big.Int).Rsh(v, 160)
+		v.And(v, mask).Or(v, wrapped)
+		state.Xor(state, v)
+	}
+	state.Xor(state, new(big.Int).Lsh(big.NewInt(int64(len(data))), 96))
+	be := state.FillBytes(make([]byte, 20))
+	le := make([]byte, 20)
+	for i := range be {
+		le[i] = be[19-i]
+	}
+	return base64.StdEncoding.EncodeToString(le)
+}
+
+func TestParseHashAlgorithms(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"", []string{"sha256"}},
+		{" , ", []string{"sha256"}},
+		{"quickxor", []string{"quickxor"}},
+		{"QuickXorHash, SHA-256", []string{"sha256", "quickxor"}},
+		{"md5,sha1,md5", []string{"sha1", "md5"}},
+		{"all", []string{"sha256", "sha1", "md5", "quickxor"}},
+	}
+	for _, tt := range tests {
+		got, err := parseHashAlgorithms(tt.input)
+		if err != nil {
+			t.Errorf("parseHashAlgorithms(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseHashAlgorithms(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+	if _, err := parseHashAlgorithms("sha256,crc32"); err == nil {
+		t.Error("parseHashAlgorithms() accepted an unknown algorithm")
+	}
+}
+
+func TestSniffMIME(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"empty", nil, mimeEmpty},
+		{"pdf", []byte("%PDF-1.7\n"), "application/pdf"},
+		{"html", []byte("\n<!DOCTYPE html><html>"), "text/html"},
+		{"zip", []byte("PK\x03\x04\x14\x00"), "application/zip"},
+		{"ole", append(append([]byte{}, oleMagic...), 0, 0), mimeOLE},
+		{"text", []byte("hello"), "text/plain"},
+		{"binary", []byte{0, 1, 2, 3}, "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		if got := sniffMIME(tt.head); got != tt.want {
+			t.Errorf("sniffMIME(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestContentMismatch(t *testing.T) {
+	tests := []struct {
+		ext, sniffed string
+		want         bool
+	}{
+		{".pdf", "application/pdf", false},
+		{".pdf", "text/html", true},
+		{".PDF", "text/html", true},
+		{".docx", "application/zip", false},
+		{".docx", "text/plain", true},
+		{".docx", mimeOLE, false}, // password- or IRM-protected
+		{".xlsx", mimeOLE, false},
+		{".zip", mimeOLE, true},
+		{".doc", mimeOLE, false},
+		{".xls", "text/html", true},
+		{".docx", mimeEmpty, false},
+		{".txt", "text/html", false},
+		{".csv", "application/zip", true},
+		{".dat", "application/zip", false}, // unknown extensions are never checked
+		{".pdf", "", false},                // not sniffed
+	}
+	for _, tt := range tests {
+		if got := contentMismatch(tt.ext, tt.sniffed); got != tt.want {
+			t.Errorf("contentMismatch(%q, %q) = %v, want %v", tt.ext, tt.sniffed, got, tt.want)
+		}
 	}
 }
 
@@ -166,7 +344,7 @@ func TestInitSchema(t *testing.T) {
 	}
 
 	// Verify tables exist
-	tables := []string{"folders", "files"}
+	tables := []string{"folders", "files", "scan_runs", "scan_errors"}
 	for _, table := range tables {
 		var count int
 		query := "SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?"
@@ -179,6 +357,73 @@ func TestInitSchema(t *testing.T) {
 	}
 }
 
+// writeBaselineCatalog creates a catalog as the first release wrote it,
+// before runs, deletion tracking and the other later columns existed.
+func writeBaselineCatalog(t *testing.T, dbPath, file string) {
+	t.Helper()
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`
+CREATE TABLE folders (
+	path TEXT PRIMARY KEY,
+	parent_path TEXT,
+	mtime_utc TEXT
+);
+CREATE TABLE files (
+	abs_path    TEXT PRIMARY KEY,
+	folder_path TEXT NOT NULL,
+	name        TEXT NOT NULL,
+	ext         TEXT,
+	size        INTEGER,
+	mtime_utc   TEXT,
+	mime        TEXT,
+	sha256      TEXT
+);`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO files(abs_path, folder_path, name, ext, size, mtime_utc, mime) VALUES(?, ?, ?, '.txt', 5, '2024-01-01T00:00:00Z', 'text/plain')`,
+		file, filepath.Dir(file), filepath.Base(file)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInitSchemaMigratesBaselineCatalog(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "a.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	writeBaselineCatalog(t, dbPath, file)
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := initSchema(db); err != nil {
+		t.Fatalf("initSchema() on a baseline catalog failed: %v", err)
+	}
+	var indexes int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_files_seen_run'`).Scan(&indexes); err != nil || indexes != 1 {
+		t.Errorf("idx_files_seen_run: %d, %v; want it created", indexes, err)
+	}
+
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir, hash: true}, func(progressMsg) {}); err != nil {
+		t.Fatalf("scanAndPersist() on a baseline catalog failed: %v", err)
+	}
+	var seenRun, sum sql.NullString
+	if err := db.QueryRow(`SELECT seen_run, sha256 FROM files WHERE abs_path = ?`, file).Scan(&seenRun, &sum); err != nil {
+		t.Fatal(err)
+	}
+	if !seenRun.Valid || !sum.Valid {
+		t.Errorf("baseline row after a scan: seen_run %v, sha256 %v; want both set", seenRun, sum)
+	}
+}
+
 func TestScanAndPersistBasic(t *testing.T) {
 	// Create a temporary directory structure
 	tmpDir := t.TempDir()
@@ -208,15 +453,16 @@ func TestScanAndPersistBasic(t *testing.T) {
 	dbPath := filepath.Join(tmpDir, "catalog.db")
 
 	// Progress callback for testing
+	var last progressMsg
 	progressCalls := 0
-	progressCallback := func(files, folders int64, last string) tea.Msg {
+	progressCallback := func(p progressMsg) {
 		progressCalls++
-		return progressMsg{files: files, folders: folders, last: last}
+		last = p
 	}
 
 	// Test scanning without extension filter
 	extFilter := map[string]struct{}{}
-	err := scanAndPersist(tmpDir, dbPath, extFilter, false, progressCallback)
+	err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir, extFilter: extFilter}, progressCallback)
 	if err != nil {
 		t.Fatalf("scanAndPersist() failed: %v", err)
 	}
@@ -246,6 +492,15 @@ func TestScanAndPersistBasic(t *testing.T) {
 	if fileCount < 3 {
 		t.Errorf("Expected at least 3 files, got %d", fileCount)
 	}
+
+	// The final progress report is always delivered with the totals
+	// (the catalog itself lives in tmpDir, so allow for its files too)
+	if progressCalls == 0 {
+		t.Fatal("Expected at least one progress report")
+	}
+	if last.files < 3 || last.bytes < int64(3*len("content1")) {
+		t.Errorf("Final progress = %d files / %d bytes, want at least 3 / %d", last.files, last.bytes, 3*len("content1"))
+	}
 }
 
 func TestScanAndPersistWithExtFilter(t *testing.T) {
@@ -271,13 +526,11 @@ func TestScanAndPersistWithExtFilter(t *testing.T) {
 	dbPath := filepath.Join(tmpDir, "catalog.db")
 
 	// Progress callback for testing
-	progressCallback := func(files, folders int64, last string) tea.Msg {
-		return progressMsg{files: files, folders: folders, last: last}
-	}
+	progressCallback := func(p progressMsg) {}
 
 	// Test scanning with extension filter (only .pdf files)
 	extFilter := map[string]struct{}{".pdf": {}}
-	err := scanAndPersist(tmpDir, dbPath, extFilter, false, progressCallback)
+	err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir, extFilter: extFilter}, progressCallback)
 	if err != nil {
 		t.Fatalf("scanAndPersist() failed: %v", err)
 	}
@@ -322,13 +575,11 @@ func TestScanAndPersistWithHashing(t *testing.T) {
 	dbPath := filepath.Join(tmpDir, "catalog.db")
 
 	// Progress callback for testing
-	progressCallback := func(files, folders int64, last string) tea.Msg {
-		return progressMsg{files: files, folders: folders, last: last}
-	}
+	progressCallback := func(p progressMsg) {}
 
 	// Test scanning with hashing enabled
 	extFilter := map[string]struct{}{}
-	err := scanAndPersist(tmpDir, dbPath, extFilter, true, progressCallback)
+	err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir, extFilter: extFilter, hash: true}, progressCallback)
 	if err != nil {
 		t.Fatalf("scanAndPersist() failed: %v", err)
 	}
@@ -362,6 +613,2070 @@ func TestScanAndPersistWithHashing(t *testing.T) {
 	}
 }
 
+func TestScanAndPersistHashWorkers(t *testing.T) {
+	tmpDir := t.TempDir()
+	for i := 0; i < 25; i++ {
+		path := filepath.Join(tmpDir, fmt.Sprintf("file%02d.txt", i))
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("content %d", i)), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+
+	opts := scanOptions{root: tmpDir, hash: true, hashWorkers: 4}
+	if err := scanAndPersist(context.Background(), dbPath, opts, nil); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Every file must reach the writer with the hash of its own content
+	rows, err := db.Query("SELECT abs_path, sha256 FROM files")
+	if err != nil {
+		t.Fatalf("Failed to query files: %v", err)
+	}
+	defer rows.Close()
+	count := 0
+	for rows.Next() {
+		var path string
+		var sum sql.NullString
+		if err := rows.Scan(&path, &sum); err != nil {
+			t.Fatalf("Failed to scan row: %v", err)
+		}
+		if want, _ := hashFile(path); sum.String != want {
+			t.Errorf("sha256 for %s = %q, want %q", path, sum.String, want)
+		}
+		count++
+	}
+	if count != 25 {
+		t.Errorf("Expected 25 files, got %d", count)
+	}
+}
+
+func TestScanAndPersistIncremental(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("original"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+
+	opts := scanOptions{root: tmpDir, hash: true, incremental: true}
+	var last progressMsg
+	if err := scanAndPersist(context.Background(), dbPath, opts, func(p progressMsg) { last = p }); err != nil {
+		t.Fatalf("first scanAndPersist() failed: %v", err)
+	}
+	if last.newFiles != 3 || last.changedFiles != 0 || last.unchangedFiles != 0 {
+		t.Errorf("first scan = %d new / %d changed / %d unchanged, want 3/0/0", last.newFiles, last.changedFiles, last.unchangedFiles)
+	}
+
+	// Change one file's size and add another; the rest must be skipped
+	if err := os.WriteFile(filepath.Join(tmpDir, "b.txt"), []byte("modified content"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "d.txt"), []byte("new"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := scanAndPersist(context.Background(), dbPath, opts, func(p progressMsg) { last = p }); err != nil {
+		t.Fatalf("second scanAndPersist() failed: %v", err)
+	}
+	if last.newFiles != 1 || last.changedFiles != 1 || last.unchangedFiles != 2 {
+		t.Errorf("second scan = %d new / %d changed / %d unchanged, want 1/1/2", last.newFiles, last.changedFiles, last.unchangedFiles)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// The changed file gets a fresh hash; unchanged ones keep theirs
+	for _, name := range []string{"a.txt", "b.txt", "d.txt"} {
+		path := filepath.Join(tmpDir, name)
+		var sum string
+		if err := db.QueryRow("SELECT sha256 FROM files WHERE abs_path = ?", path).Scan(&sum); err != nil {
+			t.Fatalf("Failed to read hash for %s: %v", name, err)
+		}
+		if want, _ := hashFile(path); sum != want {
+			t.Errorf("sha256 for %s = %q, want %q", name, sum, want)
+		}
+	}
+}
+
+func TestScanAndPersistMarksDeleted(t *testing.T) {
+	tmpDir := t.TempDir()
+	subDir := filepath.Join(tmpDir, "subdir")
+	if err := os.Mkdir(subDir, 0755); err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+	for _, path := range []string{
+		filepath.Join(tmpDir, "keep.txt"),
+		filepath.Join(tmpDir, "other.pdf"),
+		filepath.Join(subDir, "gone.txt"),
+	} {
+		if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, nil); err != nil {
+		t.Fatalf("first scanAndPersist() failed: %v", err)
+	}
+	if err := os.RemoveAll(subDir); err != nil {
+		t.Fatalf("Failed to remove subdirectory: %v", err)
+	}
+
+	// The .pdf is filtered out but still exists, so it must not be flagged
+	var last progressMsg
+	opts := scanOptions{root: tmpDir, extFilter: parseExtSet(".txt")}
+	if err := scanAndPersist(context.Background(), dbPath, opts, func(p progressMsg) { last = p }); err != nil {
+		t.Fatalf("second scanAndPersist() failed: %v", err)
+	}
+	if last.deletedFiles != 1 || last.deletedFolders != 1 {
+		t.Errorf("deleted = %d files / %d folders, want 1 / 1", last.deletedFiles, last.deletedFolders)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var deletedAt, deletedRun sql.NullString
+	if err := db.QueryRow("SELECT deleted_at, deleted_run FROM files WHERE name = 'gone.txt'").Scan(&deletedAt, &deletedRun); err != nil {
+		t.Fatalf("Failed to read deleted row: %v", err)
+	}
+	if !deletedAt.Valid || !deletedRun.Valid {
+		t.Errorf("gone.txt deleted_at=%v deleted_run=%v, want both set", deletedAt, deletedRun)
+	}
+	var livePDF int
+	if err := db.QueryRow("SELECT COUNT(*) FROM files WHERE name = 'other.pdf' AND deleted_at IS NULL").Scan(&livePDF); err != nil {
+		t.Fatalf("Failed to read pdf row: %v", err)
+	}
+	if livePDF != 1 {
+		t.Error("Filtered-out file was flagged as deleted")
+	}
+
+	files, folders, err := purgeDeleted(dbPath)
+	if err != nil {
+		t.Fatalf("purgeDeleted() failed: %v", err)
+	}
+	if files != 1 || folders != 1 {
+		t.Errorf("purgeDeleted() = %d files / %d folders, want 1 / 1", files, folders)
+	}
+}
+
+func TestDoneScreenConfirmsPurge(t *testing.T) {
+	tmpDir := t.TempDir()
+	gone := filepath.Join(tmpDir, "gone.txt")
+	if err := os.WriteFile(gone, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(gone); err != nil {
+		t.Fatal(err)
+	}
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// press feeds a key to the done screen and the message its command
+	// produces back in
+	press := func(m model, key string) model {
+		t.Helper()
+		next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		m = next.(model)
+		if cmd != nil {
+			next, _ = m.Update(cmd())
+			m = next.(model)
+		}
+		return m
+	}
+	m := model{state: stateDone, dbPath: dbPath}
+	m = press(m, "p")
+	if !m.askPurge || !strings.Contains(m.View(), "Purge 1 deleted files / 0 deleted folders for good? y/n") {
+		t.Fatalf("p did not ask first:\n%s", m.View())
+	}
+	m = press(m, "n")
+	if files, _, err := countDeleted(dbPath); err != nil || files != 1 || m.notice != "Purge cancelled" {
+		t.Fatalf("after n: %d deleted files, %v, notice %q; want the row kept", files, err, m.notice)
+	}
+	m = press(press(m, "p"), "y")
+	if files, _, err := countDeleted(dbPath); err != nil || files != 0 {
+		t.Errorf("after y: %d deleted files, %v; want them purged", files, err)
+	}
+	if m.notice != "Purged 1 deleted files and 0 deleted folders" {
+		t.Errorf("notice = %q", m.notice)
+	}
+}
+
+func TestScanAndPersistRecordsErrors(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "does-not-exist")
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+
+	var last progressMsg
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: missing}, func(p progressMsg) { last = p }); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+	if last.errors != 1 {
+		t.Fatalf("Reported %d errors, want 1", last.errors)
+	}
+
+	errs, err := loadScanErrors(dbPath, last.runID, doneErrorLimit)
+	if err != nil {
+		t.Fatalf("loadScanErrors() failed: %v", err)
+	}
+	if len(errs) != 1 || errs[0].path != missing || errs[0].op != "lstat" || errs[0].err == "" {
+		t.Errorf("loadScanErrors() = %+v, want one lstat error for %s", errs, missing)
+	}
+}
+
+func TestScanAndPersistRecordsUnreadableDir(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permission checks do not apply to root")
+	}
+	tmpDir := t.TempDir()
+	locked := filepath.Join(tmpDir, "locked")
+	if err := os.Mkdir(locked, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatalf("Failed to lock directory: %v", err)
+	}
+	defer os.Chmod(locked, 0755)
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+
+	var last progressMsg
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, func(p progressMsg) { last = p }); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+	errs, err := loadScanErrors(dbPath, last.runID, doneErrorLimit)
+	if err != nil {
+		t.Fatalf("loadScanErrors() failed: %v", err)
+	}
+	if len(errs) != 1 || errs[0].path != locked || errs[0].op != "readdir" {
+		t.Errorf("loadScanErrors() = %+v, want one readdir error for %s", errs, locked)
+	}
+}
+
+func TestScanAndPersistHashStatus(t *testing.T) {
+	tmpDir := t.TempDir()
+	kept := filepath.Join(tmpDir, "kept.txt")
+	edited := filepath.Join(tmpDir, "edited.txt")
+	for _, p := range []string{kept, edited} {
+		if err := os.WriteFile(p, []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	scan := func(opts scanOptions) progressMsg {
+		t.Helper()
+		var last progressMsg
+		opts.root = tmpDir
+		if err := scanAndPersist(context.Background(), dbPath, opts, func(p progressMsg) { last = p }); err != nil {
+			t.Fatalf("scanAndPersist() failed: %v", err)
+		}
+		return last
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	status := func(path string) (sum, status, msg sql.NullString) {
+		t.Helper()
+		if err := db.QueryRow(`SELECT sha256, hash_status, hash_error FROM files WHERE abs_path = ?`, path).Scan(&sum, &status, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return sum, status, msg
+	}
+
+	scan(scanOptions{hash: true})
+	if sum, st, _ := status(kept); !sum.Valid || st.String != hashOK {
+		t.Fatalf("after hashing: sha256 %v, hash_status %v; want a hash and ok", sum, st)
+	}
+
+	// Without hashing, an edited file loses its now stale hash
+	if err := os.WriteFile(edited, []byte("edited content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	scan(scanOptions{})
+	if sum, st, _ := status(edited); sum.Valid || st.Valid {
+		t.Errorf("edited without hashing: sha256 %v, hash_status %v; want both NULL", sum, st)
+	}
+	if sum, st, _ := status(kept); !sum.Valid || st.String != hashOK {
+		t.Errorf("unchanged without hashing: sha256 %v, hash_status %v; want them kept", sum, st)
+	}
+
+	// A failed hash leaves no checksum, so an incremental scan retries it
+	if _, err := db.Exec(`UPDATE files SET sha256 = NULL, hash_status = ?, hash_error = 'read failed' WHERE abs_path = ?`, hashError, kept); err != nil {
+		t.Fatal(err)
+	}
+	last := scan(scanOptions{hash: true, incremental: true})
+	if sum, st, msg := status(kept); !sum.Valid || st.String != hashOK || msg.Valid {
+		t.Errorf("after retry: sha256 %v, hash_status %v, hash_error %v; want a hash, ok and no error", sum, st, msg)
+	}
+	if last.changedFiles != 2 || last.hashErrors != 0 {
+		t.Errorf("retry scan: %d changed, %d hash errors; want 2 and 0", last.changedFiles, last.hashErrors)
+	}
+}
+
+func TestScanAndPersistContentMismatches(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"real.pdf":    "%PDF-1.7\n",
+		"fake.pdf":    "<!DOCTYPE html><html><body>Sign in</body></html>",
+		"real.docx":   "PK\x03\x04\x14\x00",
+		"fake.docx":   "not a zip",
+		"locked.pptx": string(oleMagic),
+		"empty.xlsx":  "",
+		"notes.dat":   "PK\x03\x04",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	scan := func(opts scanOptions) progressMsg {
+		t.Helper()
+		var last progressMsg
+		opts.root = tmpDir
+		if err := scanAndPersist(context.Background(), dbPath, opts, func(p progressMsg) { last = p }); err != nil {
+			t.Fatalf("scanAndPersist() failed: %v", err)
+		}
+		return last
+	}
+
+	if last := scan(scanOptions{sniff: true}); last.mismatches != 2 || last.errors != 0 {
+		t.Errorf("sniffing scan: %d mismatches, %d errors; want 2 and 0", last.mismatches, last.errors)
+	}
+	report, err := findMismatchesIn(dbPath)
+	if err != nil {
+		t.Fatalf("findMismatchesIn() failed: %v", err)
+	}
+	want := []contentMismatchFile{
+		{Path: filepath.Join(tmpDir, "fake.docx"), Ext: ".docx", Size: 9, Expected: "application/zip or " + mimeOLE, Sniffed: "text/plain"},
+		{Path: filepath.Join(tmpDir, "fake.pdf"), Ext: ".pdf", Size: int64(len(files["fake.pdf"])), Expected: "application/pdf", Sniffed: "text/html"},
+	}
+	if report.Sniffed != len(files) || !reflect.DeepEqual(report.Files, want) {
+		t.Errorf("findMismatchesIn() = %d sniffed, %+v; want %d, %+v", report.Sniffed, report.Files, len(files), want)
+	}
+
+	// Unchanged files keep their sniffed type, with or without sniffing
+	if last := scan(scanOptions{sniff: true, incremental: true}); last.changedFiles != 0 {
+		t.Errorf("sniffing rescan: %d changed files, want 0", last.changedFiles)
+	}
+	scan(scanOptions{})
+	if report, err := findMismatchesIn(dbPath); err != nil || report.Sniffed != len(files) {
+		t.Errorf("after a scan without sniffing: %v, %v; want every sniffed type kept", report, err)
+	}
+
+	var stdout, stderr strings.Builder
+	if code := runCLI([]string{"mismatches", "--db", dbPath, "--format", "csv"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("mismatches exit code = %d, want %d (stderr: %s)", code, exitOK, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "fake.pdf,.pdf,") || strings.Contains(stdout.String(), "real.pdf") {
+		t.Errorf("mismatches CSV should list fake.pdf only among the PDFs:\n%s", stdout.String())
+	}
+}
+
+func TestScanAndPersistHashAlgorithms(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "a.txt")
+	if err := os.WriteFile(testFile, []byte("Hello, World!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	scan := func(opts scanOptions) progressMsg {
+		t.Helper()
+		var last progressMsg
+		opts.root, opts.hash = tmpDir, true
+		if err := scanAndPersist(context.Background(), dbPath, opts, func(p progressMsg) { last = p }); err != nil {
+			t.Fatalf("scanAndPersist() failed: %v", err)
+		}
+		return last
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	sums := func() (sha256, sha1, md5, quickxor sql.NullString) {
+		t.Helper()
+		if err := db.QueryRow(`SELECT sha256, sha1, md5, quickxor FROM files WHERE abs_path = ?`, testFile).Scan(&sha256, &sha1, &md5, &quickxor); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	scan(scanOptions{hashAlgos: []string{"sha256", "quickxor"}})
+	sha256, sha1, md5, quickxor := sums()
+	if sha256.String != "dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f" || quickxor.String != quickXorReference([]byte("Hello, World!")) {
+		t.Errorf("sha256 %v, quickxor %v; want both computed", sha256, quickxor)
+	}
+	if sha1.Valid || md5.Valid {
+		t.Errorf("sha1 %v, md5 %v; want NULL when not chosen", sha1, md5)
+	}
+
+	// An incremental rescan re-hashes unchanged files missing a newly
+	// chosen algorithm, and keeps the sums it already has
+	last := scan(scanOptions{hashAlgos: []string{"md5"}, incremental: true})
+	if last.changedFiles != 1 {
+		t.Errorf("adding md5: %d changed files, want 1", last.changedFiles)
+	}
+	sha256, _, md5, quickxor = sums()
+	if md5.String != "65a8e27d8879283831b664bd8b7f0ad4" || !sha256.Valid || !quickxor.Valid {
+		t.Errorf("after adding md5: sha256 %v, md5 %v, quickxor %v; want all three", sha256, md5, quickxor)
+	}
+	if last := scan(scanOptions{hashAlgos: []string{"md5"}, incremental: true}); last.changedFiles != 0 {
+		t.Errorf("rescan with md5 stored: %d changed files, want 0", last.changedFiles)
+	}
+}
+
+func TestScanAndPersistHashError(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permission checks do not apply to root")
+	}
+	tmpDir := t.TempDir()
+	locked := filepath.Join(tmpDir, "locked.txt")
+	if err := os.WriteFile(locked, []byte("secret"), 0); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+
+	var last progressMsg
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir, hash: true}, func(p progressMsg) { last = p }); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+	if last.files != 1 || last.hashErrors != 1 || last.errors != 1 {
+		t.Errorf("progress = %d files, %d hash errors, %d errors; want 1 each", last.files, last.hashErrors, last.errors)
+	}
+	db, err := openCatalogReadOnly(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var sum, status, msg sql.NullString
+	if err := db.QueryRow(`SELECT sha256, hash_status, hash_error FROM files`).Scan(&sum, &status, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if sum.Valid || status.String != hashError || msg.String == "" {
+		t.Errorf("sha256 %v, hash_status %v, hash_error %v; want no hash, error and a message", sum, status, msg)
+	}
+	errs, err := loadScanErrors(dbPath, last.runID, doneErrorLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0].op != "hash" || errs[0].path != locked {
+		t.Errorf("loadScanErrors() = %+v, want one hash error for %s", errs, locked)
+	}
+}
+
+func TestWalkOrderCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int // sign only
+	}{
+		{"/r", "/r", 0},
+		{"/r", "/r/a", -1},
+		{"/r/a/z.txt", "/r/b", -1},
+		{"/r/b", "/r/a/z.txt", 1},
+		{"/r/a", "/r/a.txt", -1},
+		{"/r/a b", "/r/a", 1},
+		{"/r/a/x", "/r/a", 1},
+	}
+	for _, tt := range tests {
+		got := walkOrderCompare(tt.a, tt.b)
+		if (got < 0 && tt.want >= 0) || (got > 0 && tt.want <= 0) || (got == 0 && tt.want != 0) {
+			t.Errorf("walkOrderCompare(%q, %q) = %d, want sign %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestScanAndPersistResume(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"a", "b", "c"} {
+		if err := os.Mkdir(filepath.Join(tmpDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		for _, name := range []string{"1.txt", "2.txt"} {
+			if err := os.WriteFile(filepath.Join(tmpDir, dir, name), []byte(dir+name), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+
+	var first progressMsg
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, func(p progressMsg) { first = p }); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Pretend the run was interrupted right after b/1.txt was committed
+	checkpoint := filepath.Join(tmpDir, "b", "1.txt")
+	if _, err := db.Exec(`UPDATE scan_runs SET status = ?, checkpoint_path = ? WHERE id = ?`, runCancelled, checkpoint, first.runID); err != nil {
+		t.Fatalf("Failed to fake interruption: %v", err)
+	}
+	if _, err := db.Exec(`DELETE FROM files WHERE abs_path > ?`, checkpoint); err != nil {
+		t.Fatalf("Failed to drop uncommitted rows: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE files SET mime = 'committed-before'`); err != nil {
+		t.Fatalf("Failed to mark committed rows: %v", err)
+	}
+
+	var last progressMsg
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir, resume: true}, func(p progressMsg) { last = p }); err != nil {
+		t.Fatalf("resumed scanAndPersist() failed: %v", err)
+	}
+	if last.runID != first.runID {
+		t.Errorf("Resumed run id = %q, want %q", last.runID, first.runID)
+	}
+
+	var status string
+	if err := db.QueryRow("SELECT status FROM scan_runs WHERE id = ?", first.runID).Scan(&status); err != nil {
+		t.Fatalf("Failed to read run: %v", err)
+	}
+	if status != runCompleted {
+		t.Errorf("Run status = %q, want %q", status, runCompleted)
+	}
+
+	// Rows up to the checkpoint are left alone, the rest are written again
+	rows, err := db.Query("SELECT abs_path, mime FROM files ORDER BY abs_path")
+	if err != nil {
+		t.Fatalf("Failed to query files: %v", err)
+	}
+	defer rows.Close()
+	count := 0
+	for rows.Next() {
+		var path, mime string
+		if err := rows.Scan(&path, &mime); err != nil {
+			t.Fatalf("Failed to scan row: %v", err)
+		}
+		skipped := walkOrderCompare(path, checkpoint) <= 0
+		if skipped != (mime == "committed-before") {
+			t.Errorf("%s: mime %q, skipped=%v", path, mime, skipped)
+		}
+		count++
+	}
+	if count != 6 {
+		t.Errorf("Expected 6 files after resume, got %d", count)
+	}
+}
+
+func TestScanAndPersistResumeAfterCrash(t *testing.T) {
+	tmpDir := t.TempDir()
+	const total = 2500
+	for i := 0; i < total; i++ {
+		if err := os.WriteFile(filepath.Join(tmpDir, fmt.Sprintf("f%04d.txt", i)), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Capture the run row as a crash part way through would leave it: only
+	// what the batch commits wrote, and no finishRun
+	type runRow struct {
+		checkpoint                  sql.NullString
+		files, folders, bytes, errs sql.NullInt64
+	}
+	var crashed runRow
+	var first progressMsg
+	err = scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, func(p progressMsg) {
+		first = p
+		if p.files >= 1200 && !crashed.checkpoint.Valid {
+			if err := db.QueryRow(`SELECT checkpoint_path, files, folders, bytes, errors FROM scan_runs WHERE id = ?`, p.runID).
+				Scan(&crashed.checkpoint, &crashed.files, &crashed.folders, &crashed.bytes, &crashed.errs); err != nil {
+				t.Errorf("reading the running scan: %v", err)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+	if !crashed.checkpoint.Valid || !crashed.files.Valid || crashed.files.Int64 == 0 {
+		t.Fatalf("mid-scan run row = %+v; want a checkpoint with running totals", crashed)
+	}
+
+	if _, err := db.Exec(`UPDATE scan_runs SET status = ?, finished_utc = NULL, checkpoint_path = ?, files = ?, folders = ?, bytes = ?, errors = ? WHERE id = ?`,
+		runRunning, crashed.checkpoint, crashed.files, crashed.folders, crashed.bytes, crashed.errs, first.runID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`DELETE FROM files WHERE abs_path > ?`, crashed.checkpoint.String); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir, resume: true}, func(progressMsg) {}); err != nil {
+		t.Fatalf("resumed scanAndPersist() failed: %v", err)
+	}
+	var files, folders int64
+	if err := db.QueryRow(`SELECT files, folders FROM scan_runs WHERE id = ?`, first.runID).Scan(&files, &folders); err != nil {
+		t.Fatal(err)
+	}
+	if files != total || folders != 1 {
+		t.Errorf("resumed run counts %d files, %d folders; want %d and 1", files, folders, total)
+	}
+}
+
+func TestScanAndPersistCancelled(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "file1.txt"), []byte("content1"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+
+	// A context cancelled up front stops the walk before anything is written
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var last progressMsg
+	err := scanAndPersist(ctx, dbPath, scanOptions{root: tmpDir}, func(p progressMsg) { last = p })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("scanAndPersist() error = %v, want context.Canceled", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// The summary reported to the UI must match what was committed
+	var fileCount int64
+	if err := db.QueryRow("SELECT COUNT(*) FROM files").Scan(&fileCount); err != nil {
+		t.Fatalf("Failed to count files: %v", err)
+	}
+	if fileCount != last.files {
+		t.Errorf("Committed %d files but reported %d", fileCount, last.files)
+	}
+
+	var status string
+	if err := db.QueryRow("SELECT status FROM scan_runs WHERE id = ?", last.runID).Scan(&status); err != nil {
+		t.Fatalf("Failed to read scan run: %v", err)
+	}
+	if status != runCancelled {
+		t.Errorf("Run status = %q, want %q", status, runCancelled)
+	}
+}
+
+func TestScanAndPersistCancelledWhileHashing(t *testing.T) {
+	if testing.Short() {
+		t.Skip("hashes large files")
+	}
+	tmpDir := t.TempDir()
+	const total = 6
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<20) // 16MB
+	for i := 0; i < total; i++ {
+		if err := os.WriteFile(filepath.Join(tmpDir, fmt.Sprintf("f%d.bin", i)), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	opts := scanOptions{root: tmpDir, hash: true, hashAlgos: []string{"sha256", "sha1", "md5", "quickxor"}, hashWorkers: 1}
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, func(progressMsg) {}); err != nil {
+		t.Fatalf("first scanAndPersist() failed: %v", err)
+	}
+
+	// Stop once the walk has finished but files are still being hashed;
+	// the files still queued are dropped, so the run must not count as
+	// complete or mark anything deleted
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var last progressMsg
+	stoppedEarly := false
+	err := scanAndPersist(ctx, dbPath, opts, func(p progressMsg) {
+		last = p
+		if p.totalFinal && p.files > 0 && p.files < total && ctx.Err() == nil {
+			stoppedEarly = true
+			cancel()
+		}
+	})
+	if !stoppedEarly {
+		t.Skip("hashing finished before progress was reported")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("scanAndPersist() error = %v after stopping at %d of %d files, want context.Canceled", err, last.files, total)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var status string
+	if err := db.QueryRow(`SELECT status FROM scan_runs WHERE id = ?`, last.runID).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+	var deleted int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM files WHERE deleted_at IS NOT NULL`).Scan(&deleted); err != nil {
+		t.Fatal(err)
+	}
+	if status != runCancelled || deleted != 0 {
+		t.Errorf("run status %q with %d files marked deleted; want %q and none", status, deleted, runCancelled)
+	}
+}
+
+func TestScanAndPersistRecordsRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.pdf", "b.pdf", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("content"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+
+	var last progressMsg
+	opts := scanOptions{root: tmpDir, extFilter: parseExtSet(".pdf"), hash: true, hashWorkers: 2}
+	if err := scanAndPersist(context.Background(), dbPath, opts, func(p progressMsg) { last = p }); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+	if last.runID == "" {
+		t.Fatal("Expected progress to carry the run id")
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var (
+		root, extFilter, options, status string
+		hash, files, folders, bytes      int64
+		finished                         sql.NullString
+	)
+	err = db.QueryRow(`SELECT root, ext_filter, hash, options, status, files, folders, bytes, finished_utc
+		FROM scan_runs WHERE id = ?`, last.runID).Scan(&root, &extFilter, &hash, &options, &status, &files, &folders, &bytes, &finished)
+	if err != nil {
+		t.Fatalf("Failed to read scan run: %v", err)
+	}
+	if root != filepath.Clean(tmpDir) || extFilter != ".pdf" || hash != 1 {
+		t.Errorf("Run settings = %q / %q / %d, want %q / .pdf / 1", root, extFilter, hash, tmpDir)
+	}
+	if status != runCompleted || !finished.Valid {
+		t.Errorf("Run status = %q finished=%v, want completed with a finish time", status, finished)
+	}
+	if files != 2 || folders != 1 || bytes != int64(2*len("content")) {
+		t.Errorf("Run counts = %d files / %d folders / %d bytes, want 2 / 1 / %d", files, folders, bytes, 2*len("content"))
+	}
+	if !strings.Contains(options, `"hash_workers":2`) {
+		t.Errorf("Run options %s missing hash_workers", options)
+	}
+
+	var linked int
+	if err := db.QueryRow("SELECT COUNT(*) FROM files WHERE seen_run = ?", last.runID).Scan(&linked); err != nil {
+		t.Fatalf("Failed to count linked files: %v", err)
+	}
+	if linked != 2 {
+		t.Errorf("Expected 2 files linked to the run, got %d", linked)
+	}
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := filepath.FromSlash("/data/site")
+	m := newIgnoreMatcher(root, parseExcludeList("node_modules, ~$*, Forms/, /Archive, **/drafts/*.tmp, *.log, !keep.log, ..foo"))
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		excluded bool
+	}{
+		{"", true, false},
+		{"node_modules", true, true},
+		{"a/b/node_modules", true, true},
+		{"Docs/~$report.docx", false, true},
+		{"Docs/report.docx", false, false},
+		{"Shared/Forms", true, true},
+		{"Shared/Forms", false, false}, // dir-only pattern
+		{"Archive", true, true},
+		{"Team/Archive", true, false}, // anchored to the root
+		{"drafts/x.tmp", false, true},
+		{"a/b/drafts/x.tmp", false, true},
+		{"a/x.tmp", false, false},
+		{"run.log", false, true},
+		{"keep.log", false, false}, // negated
+		{"..foo", false, true},     // inside the root despite the leading dots
+		{"..foo.log", false, true},
+	}
+	for _, tt := range tests {
+		p := filepath.Join(root, filepath.FromSlash(tt.path))
+		if got := m.excluded(p, tt.isDir); got != tt.excluded {
+			t.Errorf("excluded(%q, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.excluded)
+		}
+	}
+}
+
+func TestScanAndPersistExclude(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, p := range []string{
+		"keep.txt",
+		"~$lock.docx",
+		"node_modules/pkg/index.js",
+		"Shared/Forms/AllItems.aspx",
+		"Shared/doc.pdf",
+		"Team/scratch.tmp",
+		"Team/notes.txt",
+	} {
+		full := filepath.Join(tmpDir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A nested ignore file only applies below its folder
+	if err := os.WriteFile(filepath.Join(tmpDir, "Team", ignoreFileName), []byte("# scratch files\n*.tmp\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := scanOptions{root: tmpDir, exclude: parseExcludeList("node_modules, ~$*, Forms/")}
+
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	var last progressMsg
+	if err := scanAndPersist(context.Background(), dbPath, opts, func(p progressMsg) { last = p }); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+	if !last.totalFinal || last.estimatedTotal != 4 {
+		t.Errorf("final total = %d (final %v), want 4", last.estimatedTotal, last.totalFinal)
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT name FROM files ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, n)
+	}
+	want := []string{ignoreFileName, "doc.pdf", "keep.txt", "notes.txt"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", names, want)
+	}
+
+	// Pruned folders are not cataloged either
+	var folders int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM folders WHERE path LIKE '%node_modules%' OR path LIKE '%Forms%'`).Scan(&folders); err != nil {
+		t.Fatal(err)
+	}
+	if folders != 0 {
+		t.Errorf("excluded folders cataloged: %d", folders)
+	}
+}
+
+func TestParseFileFilter(t *testing.T) {
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, time.Local)
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.Local) }
+
+	tests := []struct {
+		size, modified string
+		want           fileFilter
+		wantErr        bool
+	}{
+		{"", "", fileFilter{}, false},
+		{">10MB", "", fileFilter{sizeAtLeast: 10<<20 + 1}, false},
+		{">=1kb, <2 GiB", "", fileFilter{sizeAtLeast: 1 << 10, sizeBelow: 2 << 30}, false},
+		{"1MB-1GB", "", fileFilter{sizeAtLeast: 1 << 20, sizeBelow: 1<<30 + 1}, false},
+		{"1TB-2PiB", "", fileFilter{sizeAtLeast: 1 << 40, sizeBelow: 2<<50 + 1}, false},
+		{"", "older than 3y", fileFilter{modifiedBefore: now.AddDate(-3, 0, 0)}, false},
+		{"", "newer than 30d", fileFilter{modifiedSince: now.AddDate(0, 0, -30)}, false},
+		{"", "since 2024-01-01", fileFilter{modifiedSince: day(2024, 1, 1)}, false},
+		{"", "<=2024-01-01", fileFilter{modifiedBefore: day(2024, 1, 2)}, false},
+		{"", "2023-01-01..2023-12-31", fileFilter{modifiedSince: day(2023, 1, 1), modifiedBefore: day(2024, 1, 1)}, false},
+		{"10", "", fileFilter{}, true},
+		{">10XB", "", fileFilter{}, true},
+		{"<9000PB", "", fileFilter{}, true},
+		{"<1MB, >2MB", "", fileFilter{}, true},
+		{"", "since yesterday", fileFilter{}, true},
+		{"", "older than 3 fortnights", fileFilter{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseFileFilter(tt.size, tt.modified, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFileFilter(%q, %q) error = %v, wantErr %v", tt.size, tt.modified, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (got.sizeAtLeast != tt.want.sizeAtLeast || got.sizeBelow != tt.want.sizeBelow ||
+			!got.modifiedSince.Equal(tt.want.modifiedSince) || !got.modifiedBefore.Equal(tt.want.modifiedBefore)) {
+			t.Errorf("parseFileFilter(%q, %q) = %+v, want %+v", tt.size, tt.modified, got, tt.want)
+		}
+	}
+}
+
+func TestScanAndPersistFileFilter(t *testing.T) {
+	tmpDir := t.TempDir()
+	old := time.Now().AddDate(-5, 0, 0)
+	for _, f := range []struct {
+		name  string
+		size  int
+		mtime time.Time
+	}{
+		{"old-large.bin", 4096, old},
+		{"old-small.bin", 10, old},
+		{"new-large.bin", 4096, time.Now()},
+	} {
+		p := filepath.Join(tmpDir, f.name)
+		if err := os.WriteFile(p, make([]byte, f.size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, f.mtime, f.mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter, err := parseFileFilter(">1KB", "older than 3y", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := scanOptions{root: tmpDir, filter: filter}
+
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	var last progressMsg
+	if err := scanAndPersist(context.Background(), dbPath, opts, func(p progressMsg) { last = p }); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+	if !last.totalFinal || last.estimatedTotal != 1 {
+		t.Errorf("final total = %d (final %v), want 1", last.estimatedTotal, last.totalFinal)
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var count int
+	var name string
+	if err := db.QueryRow(`SELECT COUNT(*), MAX(name) FROM files`).Scan(&count, &name); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || name != "old-large.bin" {
+		t.Errorf("cataloged %d files (last %q), want only old-large.bin", count, name)
+	}
+}
+
+func TestScanAndPersistEstimateFromPreviousRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(filepath.Join(tmpDir, fmt.Sprintf("f%d.txt", i)), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+
+	// The first run has nothing to go on until its walk ends
+	var first []progressMsg
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, func(p progressMsg) { first = append(first, p) }); err != nil {
+		t.Fatalf("first scan failed: %v", err)
+	}
+	if last := first[len(first)-1]; !last.totalFinal || last.estimatedTotal != 3 || last.discovered != 3 {
+		t.Errorf("first run final progress = %+v, want exact total 3", last)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "f3.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var second []progressMsg
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, func(p progressMsg) { second = append(second, p) }); err != nil {
+		t.Fatalf("second scan failed: %v", err)
+	}
+	for _, p := range second {
+		if p.estimatedTotal < 3 {
+			t.Errorf("estimate %d below previous run's 3 files", p.estimatedTotal)
+		}
+	}
+	if last := second[len(second)-1]; !last.totalFinal || last.estimatedTotal != 4 {
+		t.Errorf("second run final total = %d (final %v), want 4", last.estimatedTotal, last.totalFinal)
+	}
+}
+
+func TestParallelWalkDirMatchesWalkDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, p := range []string{
+		"a/1.txt", "a/b/2.txt", "a/b/c/3.txt", "a/skip/4.txt",
+		"d/5.txt", "d/e/6.txt", "d/stop.txt", "d/z.txt", "f.txt",
+	} {
+		full := filepath.Join(tmpDir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Skipping a directory prunes it; skipping on a file ends its folder
+	visit := func(seen *[]string) fs.WalkDirFunc {
+		return func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			*seen = append(*seen, p)
+			switch d.Name() {
+			case "skip":
+				return fs.SkipDir
+			case "stop.txt":
+				return fs.SkipDir
+			}
+			return nil
+		}
+	}
+	var want, got []string
+	if err := filepath.WalkDir(tmpDir, visit(&want)); err != nil {
+		t.Fatal(err)
+	}
+	prune := func(p string, d fs.DirEntry) bool { return d.Name() == "skip" }
+	if err := parallelWalkDir(context.Background(), tmpDir, 4, prune, nil, visit(&got)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("parallelWalkDir order:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// A missing root is reported to fn like filepath.WalkDir does
+	var rootErr error
+	_ = parallelWalkDir(context.Background(), filepath.Join(tmpDir, "missing"), 4, nil, nil, func(p string, d fs.DirEntry, err error) error {
+		rootErr = err
+		return nil
+	})
+	if !errors.Is(rootErr, fs.ErrNotExist) {
+		t.Errorf("missing root error = %v, want fs.ErrNotExist", rootErr)
+	}
+}
+
+func TestScanAndPersistSymlinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	realDir := filepath.Join(tmpDir, "real")
+	if err := os.MkdirAll(filepath.Join(realDir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(realDir, "a.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(realDir, "sub", "b.txt"), []byte("world"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"link_file": filepath.Join(realDir, "a.txt"),
+		"link_dir":  filepath.Join(realDir, "sub"),
+		"real/loop": realDir, // points at its own folder
+		"broken":    filepath.Join(tmpDir, "missing"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(tmpDir, filepath.FromSlash(name))); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+
+	tests := []struct {
+		policy   symlinkPolicy
+		files    map[string]string // path relative to root -> entry_type
+		hashLink bool
+	}{
+		{linkRecord, map[string]string{
+			"real/a.txt": entryFile, "real/sub/b.txt": entryFile,
+			"link_file": entrySymlink, "link_dir": entrySymlink, "real/loop": entrySymlink, "broken": entrySymlink,
+		}, false},
+		{linkIgnore, map[string]string{
+			"real/a.txt": entryFile, "real/sub/b.txt": entryFile,
+		}, false},
+		{linkFollow, map[string]string{
+			"real/a.txt": entryFile, "real/sub/b.txt": entryFile,
+			"link_file": entrySymlink, "link_dir/b.txt": entryFile, "real/loop": entrySymlink, "broken": entrySymlink,
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			dbPath := filepath.Join(t.TempDir(), "catalog.db")
+			if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir, hash: true, symlinks: tt.policy}, nil); err != nil {
+				t.Fatalf("scanAndPersist() failed: %v", err)
+			}
+			db, err := sql.Open("sqlite", dbPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			rows, err := db.Query(`SELECT abs_path, entry_type, link_target, sha256 FROM files`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			got := map[string]string{}
+			for rows.Next() {
+				var path, entryType string
+				var target, sum sql.NullString
+				if err := rows.Scan(&path, &entryType, &target, &sum); err != nil {
+					t.Fatal(err)
+				}
+				rel, _ := filepath.Rel(tmpDir, path)
+				rel = filepath.ToSlash(rel)
+				got[rel] = entryType
+				if want, ok := links[rel]; ok && entryType == entrySymlink && target.String != want {
+					t.Errorf("%s link_target = %q, want %q", rel, target.String, want)
+				}
+				if rel == "link_file" && sum.Valid != tt.hashLink {
+					t.Errorf("link_file hashed = %v, want %v", sum.Valid, tt.hashLink)
+				}
+			}
+			if len(got) != len(tt.files) {
+				t.Errorf("files = %v, want %v", got, tt.files)
+			}
+			for rel, want := range tt.files {
+				if got[rel] != want {
+					t.Errorf("%s entry_type = %q, want %q", rel, got[rel], want)
+				}
+			}
+		})
+	}
+}
+
+func TestExportXLSX(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"Finance", "HR"} {
+		if err := os.Mkdir(filepath.Join(tmpDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range map[string]string{
+		"Finance/budget.xlsx":   "12345",
+		"Finance/notes & ü.txt": "1",
+		"HR/policy.pdf":         "123",
+		"readme.txt":            "12",
+	} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, nil); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+
+	// Split long sheets early to exercise continuation sheets
+	defer func(n int) { xlsxMaxRows = n }(xlsxMaxRows)
+	xlsxMaxRows = 3
+
+	var buf bytes.Buffer
+	n, err := exportCatalog(dbPath, &buf, exportOptions{format: "xlsx"})
+	if err != nil {
+		t.Fatalf("exportCatalog(xlsx) failed: %v", err)
+	}
+	if n != 4 {
+		t.Errorf("exported %d files, want 4", n)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("export is not a zip archive: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = string(data)
+		if strings.HasSuffix(f.Name, ".xml") || strings.HasSuffix(f.Name, ".rels") {
+			if err := xml.Unmarshal(data, new(struct{})); err != nil {
+				t.Errorf("%s is not well-formed XML: %v", f.Name, err)
+			}
+		}
+	}
+	for _, want := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		if _, ok := parts[want]; !ok {
+			t.Errorf("workbook has no %s", want)
+		}
+	}
+
+	// 4 files at 2 per sheet, then folders (root, Finance, HR) and the summaries
+	wantSheets := []string{"Files", "Files (2)", "Folders", "Folders (2)", "By Extension", "By Extension (2)", "By Top Folder", "By Top Folder (2)"}
+	for i, name := range wantSheets {
+		if !strings.Contains(parts["xl/workbook.xml"], fmt.Sprintf(`<sheet name="%s" sheetId="%d"`, name, i+1)) {
+			t.Errorf("workbook.xml has no sheet %q at %d: %s", name, i+1, parts["xl/workbook.xml"])
+		}
+	}
+	if _, ok := parts[fmt.Sprintf("xl/worksheets/sheet%d.xml", len(wantSheets)+1)]; ok {
+		t.Errorf("workbook has more than %d sheets", len(wantSheets))
+	}
+
+	files := parts["xl/worksheets/sheet1.xml"] + parts["xl/worksheets/sheet2.xml"]
+	for _, want := range []string{
+		`<t xml:space="preserve">notes &amp; ü.txt</t>`, // escaped, non-ASCII kept
+		`<c r="E2" s="2"><v>5</v></c>`,                  // size as a number
+		`<c r="F2" s="3"><v>`,                           // mtime as a date
+		`<t xml:space="preserve">abs_path</t>`,          // header repeated on the continuation sheet
+	} {
+		if !strings.Contains(files, want) {
+			t.Errorf("Files sheets lack %s:\n%s", want, files)
+		}
+	}
+	if c := strings.Count(files, "abs_path</t>"); c != 2 {
+		t.Errorf("Files header appears %d times, want 2", c)
+	}
+
+	tops := parts["xl/worksheets/sheet7.xml"] + parts["xl/worksheets/sheet8.xml"]
+	for _, want := range []string{">Finance<", ">HR<", ">(root)<", `<c r="D2" s="2"><v>6</v></c>`} {
+		if !strings.Contains(tops, want) {
+			t.Errorf("By Top Folder lacks %s:\n%s", want, tops)
+		}
+	}
+
+	if got := xlsxColumn(0) + xlsxColumn(25) + xlsxColumn(26) + xlsxColumn(701) + xlsxColumn(702); got != "AZAAZZAAA" {
+		t.Errorf("xlsxColumn = %q, want AZAAZZAAA", got)
+	}
+}
+
+func TestBuildCatalogReport(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "Sites", "Archive"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"Sites/a.docx":          "same content",
+		"Sites/Archive/b.docx":  "same content",
+		"Sites/<script>.txt":    "x",
+		"Sites/Archive/old.pdf": strings.Repeat("p", 100),
+	} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	old := now.AddDate(-4, 0, 0)
+	if err := os.Chtimes(filepath.Join(tmpDir, "Sites", "Archive", "old.pdf"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir, hash: true}, nil); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+
+	r, err := buildCatalogReport(dbPath, now)
+	if err != nil {
+		t.Fatalf("buildCatalogReport() failed: %v", err)
+	}
+	if r.Files != 4 || r.Folders != 3 || r.Bytes != 125 || r.HashedFiles != 4 {
+		t.Errorf("totals = %d files, %d folders, %d bytes, %d hashed; want 4, 3, 125, 4", r.Files, r.Folders, r.Bytes, r.HashedFiles)
+	}
+	if r.Run == nil || r.Run.Root != tmpDir || r.Run.Status != runCompleted {
+		t.Errorf("run = %+v, want the completed scan of %s", r.Run, tmpDir)
+	}
+	if len(r.Extensions) != 3 || r.Extensions[0].Name != ".pdf" {
+		t.Errorf("extensions = %+v, want .pdf first of 3", r.Extensions)
+	}
+	if len(r.LargestFiles) == 0 || filepath.Base(r.LargestFiles[0].Path) != "old.pdf" {
+		t.Errorf("largest files = %+v, want old.pdf first", r.LargestFiles)
+	}
+	if len(r.LargestFolders) != 3 || r.LargestFolders[0].Name != tmpDir || r.LargestFolders[0].Bytes != 125 {
+		t.Errorf("largest folders = %+v, want %s with everything first", r.LargestFolders, tmpDir)
+	}
+	ages := map[string]int64{}
+	for _, a := range r.Ages {
+		ages[a.Name] = a.Files
+	}
+	if ages["Last 30 days"] != 3 || ages["3–5 years"] != 1 {
+		t.Errorf("ages = %v, want 3 recent and 1 from 3-5 years ago", ages)
+	}
+	if r.DuplicateGroups != 1 || r.DuplicateWasted != 12 || len(r.Duplicates) != 1 || len(r.Duplicates[0].Files) != 2 {
+		t.Errorf("duplicates = %+v (%d groups, %d wasted), want one pair wasting 12 bytes", r.Duplicates, r.DuplicateGroups, r.DuplicateWasted)
+	}
+
+	var buf strings.Builder
+	if err := writeHTMLReport(&buf, r); err != nil {
+		t.Fatalf("writeHTMLReport() failed: %v", err)
+	}
+	html := buf.String()
+	if strings.Contains(html, "<script>") || !strings.Contains(html, "&lt;script&gt;.txt") {
+		t.Error("file names are not escaped in the report")
+	}
+	for _, want := range []string{"Files Cataloged", "b.docx", "Sets of identical files: 1", "3–5 years"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report lacks %q", want)
+		}
+	}
+}
+
+func TestCatalogExplorer(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "big", "deeper"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(tmpDir, "small"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, size := range map[string]int{
+		"top.txt":          5,
+		"big/a.bin":        100,
+		"big/deeper/b.bin": 300,
+		"small/c.txt":      10,
+	} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, nil); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+
+	treeMsg, ok := openExplorer(dbPath, "")().(explorerTreeMsg)
+	if !ok || treeMsg.err != nil {
+		t.Fatalf("openExplorer() = %+v", treeMsg)
+	}
+	if treeMsg.root != tmpDir {
+		t.Errorf("explorer root = %q, want %q", treeMsg.root, tmpDir)
+	}
+	big := filepath.Join(tmpDir, "big")
+	if got := *treeMsg.tree.totals[tmpDir]; got != (folderTotal{files: 4, folders: 3, bytes: 415}) {
+		t.Errorf("root totals = %+v, want 4 files, 3 folders, 415 bytes", got)
+	}
+	if got := *treeMsg.tree.totals[big]; got != (folderTotal{files: 2, folders: 1, bytes: 400}) {
+		t.Errorf("big totals = %+v, want 2 files, 1 folder, 400 bytes", got)
+	}
+
+	names := func(m model) []string {
+		var out []string
+		for _, e := range m.explorer.entries {
+			out = append(out, e.name)
+		}
+		return out
+	}
+	run := func(m model, cmd tea.Cmd) model {
+		for cmd != nil {
+			var next tea.Model
+			next, cmd = m.Update(cmd())
+			m = next.(model)
+		}
+		return m
+	}
+	key := func(m model, k string) model {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		switch k {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "backspace":
+			msg = tea.KeyMsg{Type: tea.KeyBackspace}
+		}
+		next, cmd := m.Update(msg)
+		return run(next.(model), cmd)
+	}
+
+	m := model{state: stateDone, dbPath: dbPath}
+	m = key(m, "b")
+	if m.state != stateExplorer || m.explorer.current != tmpDir {
+		t.Fatalf("after b: state %v at %q, err %q", m.state, m.explorer.current, m.explorer.err)
+	}
+	// Folders first, largest first
+	if got := strings.Join(names(m), ","); got != "big,small,top.txt" {
+		t.Errorf("root listing = %s, want big,small,top.txt", got)
+	}
+	m = key(m, "s")
+	m = key(m, "s")
+	if got := strings.Join(names(m), ","); m.explorer.sortBy != sortByName || got != "big,small,top.txt" {
+		t.Errorf("sorted by %v: %s", m.explorer.sortBy, got)
+	}
+
+	m = key(m, "enter")
+	if m.explorer.current != big || strings.Join(names(m), ",") != "..,deeper,a.bin" {
+		t.Errorf("after enter: at %q with %v", m.explorer.current, names(m))
+	}
+	m = key(m, "backspace")
+	if m.explorer.current != tmpDir || m.explorer.entries[m.explorer.selected].path != big {
+		t.Errorf("after backspace: at %q with %q selected", m.explorer.current, m.explorer.entries[m.explorer.selected].path)
+	}
+	m = key(m, "q")
+	if m.state != stateDone {
+		t.Errorf("q left the explorer in state %v, want the results screen", m.state)
+	}
+}
+
+func TestDoneScreenReportsScanProblems(t *testing.T) {
+	m := model{state: stateScanning, dbPath: filepath.Join("out", "catalog.db"), start: time.Now()}
+	next, _ := m.Update(progressMsg{runID: "run1", files: 3, errors: 1, hashErrors: 1, mismatches: 2})
+	next, _ = next.(model).Update(doneMsg{scanErrors: []scanErrorRecord{{path: "a.pdf", op: "hash", err: "read failed"}}})
+	m = next.(model)
+	if m.state != stateDone {
+		t.Fatalf("state = %v after doneMsg, want the results screen", m.state)
+	}
+	view := m.View()
+	for _, want := range []string{
+		"Hash Failures", "an incremental scan with hashing retries just those",
+		"Content Mismatches", "2 files have content that contradicts their extension", "spcatalog mismatches --db " + m.dbPath,
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("results screen does not show %q:\n%s", want, view)
+		}
+	}
+}
+
+func TestSearchCatalog(t *testing.T) {
+	tmpDir := t.TempDir()
+	now := time.Now()
+	for _, f := range []struct {
+		name string
+		size int
+		age  int // days
+	}{
+		{"Budget 2024.xlsx", 2048, 10},
+		{"budget_old.xlsx", 100, 800},
+		{"budgetXold.pdf", 5000, 10},
+		{"100% done.docx", 10, 1},
+		{"notes.txt", 1, 1},
+	} {
+		p := filepath.Join(tmpDir, f.name)
+		if err := os.WriteFile(p, make([]byte, f.size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mt := now.AddDate(0, 0, -f.age)
+		if err := os.Chtimes(p, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, nil); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query searchQuery
+		want  []string
+	}{
+		{"everything", searchQuery{}, []string{"100% done.docx", "Budget 2024.xlsx", "budget_old.xlsx", "budgetXold.pdf", "notes.txt"}},
+		{"substring ignores case", searchQuery{name: "BUDGET"}, []string{"Budget 2024.xlsx", "budget_old.xlsx", "budgetXold.pdf"}},
+		{"underscore is literal", searchQuery{name: "_old"}, []string{"budget_old.xlsx"}},
+		{"percent is literal", searchQuery{name: "0%"}, []string{"100% done.docx"}},
+		{"extension", searchQuery{name: "budget", ext: "xlsx"}, []string{"Budget 2024.xlsx", "budget_old.xlsx"}},
+		{"size", searchQuery{size: ">1KB"}, []string{"Budget 2024.xlsx", "budgetXold.pdf"}},
+		{"modified", searchQuery{name: "budget", modified: "older than 1y"}, []string{"budget_old.xlsx"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, more, err := searchCatalog(dbPath, tt.query, now)
+			if err != nil {
+				t.Fatalf("searchCatalog() failed: %v", err)
+			}
+			var got []string
+			for _, r := range results {
+				got = append(got, r.name)
+			}
+			if more || strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("searchCatalog(%+v) = %q (more %v), want %q", tt.query, got, more, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := searchCatalog(dbPath, searchQuery{size: "huge"}, now); err == nil {
+		t.Error("searchCatalog() accepted a bad size filter")
+	}
+	if _, _, err := searchCatalog(filepath.Join(tmpDir, "missing.db"), searchQuery{}, now); err == nil {
+		t.Error("searchCatalog() succeeded without a catalog")
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(tmpDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i, f := range []struct{ name, content string }{
+		{"a/one.txt", "same content"}, // oldest copy, kept
+		{"b/two.txt", "same content"},
+		{"b/three.txt", "same content"},
+		{"other.txt", "diff content"}, // same size, different content
+		{"x.bin", "xy"},
+		{"y.bin", "xy"},
+		{"s1.dat", "stale-data"},
+		{"s2.dat", "stale-data"}, // changed after the scan
+		{"empty1", ""},
+		{"empty2", ""},
+	} {
+		p := filepath.Join(tmpDir, f.name)
+		if err := os.WriteFile(p, []byte(f.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mt := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(p, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, nil); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+	stale := filepath.Join(tmpDir, "s2.dat")
+	if err := os.WriteFile(stale, []byte("stale-DATA"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without hashing, same-size files are found but cannot be compared
+	r, err := findDuplicatesIn(context.Background(), dbPath, dupeOptions{})
+	if err != nil {
+		t.Fatalf("findDuplicatesIn() failed: %v", err)
+	}
+	if len(r.Groups) != 0 || r.Candidates != 8 || r.Unhashed != 8 {
+		t.Errorf("without hashing: %d groups, %d candidates, %d unhashed; want 0, 8, 8", len(r.Groups), r.Candidates, r.Unhashed)
+	}
+
+	var progress int
+	r, err = findDuplicatesIn(context.Background(), dbPath, dupeOptions{hashMissing: true, workers: 2, onHash: func(done, total int) { progress = done }})
+	if err != nil {
+		t.Fatalf("findDuplicatesIn() failed: %v", err)
+	}
+	if r.Hashed != 7 || r.Unhashed != 1 || progress != 8 {
+		t.Errorf("hashed %d, unhashed %d, progress %d; want 7, 1 (the changed file), 8", r.Hashed, r.Unhashed, progress)
+	}
+	if len(r.Groups) != 2 || r.Wasted != 26 {
+		t.Fatalf("groups = %+v, wasted %d; want 2 groups wasting 26 bytes", r.Groups, r.Wasted)
+	}
+	g := r.Groups[0]
+	if g.Size != 12 || g.Wasted != 24 || len(g.Files) != 3 || g.Files[0].Path != filepath.Join(tmpDir, "a", "one.txt") {
+		t.Errorf("largest group = %+v, want 3 copies of 12 bytes, a/one.txt first", g)
+	}
+	if want := (dupeFolder{Folder: filepath.Join(tmpDir, "b"), Copies: 2, Wasted: 24}); len(r.Folders) != 2 || r.Folders[0] != want {
+		t.Errorf("folders = %+v, want %+v first", r.Folders, want)
+	}
+
+	// The sums were stored, so nothing is hashed again
+	db, err := openCatalogReadOnly(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored int
+	if err := db.QueryRow("SELECT COUNT(*) FROM files WHERE sha256 IS NOT NULL").Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if stored != 7 {
+		t.Errorf("%d hashes stored, want 7", stored)
+	}
+	r, err = findDuplicatesIn(context.Background(), dbPath, dupeOptions{minSize: 5})
+	if err != nil {
+		t.Fatalf("findDuplicatesIn() failed: %v", err)
+	}
+	if r.Hashed != 0 || len(r.Groups) != 1 || r.Groups[0].Size != 12 {
+		t.Errorf("min size 5: hashed %d, groups %+v; want only the 12-byte group", r.Hashed, r.Groups)
+	}
+
+	var csvOut bytes.Buffer
+	if err := writeDupesCSV(&csvOut, r); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&csvOut).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[1][4] != "true" || records[2][4] != "false" || records[1][5] != g.Files[0].Path {
+		t.Errorf("CSV = %q", records)
+	}
+
+	var jsonOut bytes.Buffer
+	if err := writeDupesJSON(&jsonOut, &dupeReport{}); err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(jsonOut.Bytes(), &decoded); err != nil {
+		t.Fatalf("JSON output is invalid: %v", err)
+	}
+	if groups, ok := decoded["groups"].([]any); !ok || len(groups) != 0 || decoded["group_count"] != float64(0) {
+		t.Errorf("empty report JSON = %s, want empty groups", jsonOut.String())
+	}
+
+	// The results screen opens the duplicates view
+	m := model{state: stateDone, dbPath: dbPath}
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	m = next.(model)
+	if m.state != stateDupes || cmd == nil {
+		t.Fatalf("d on the results screen: state %v, cmd %v", m.state, cmd)
+	}
+	next, _ = m.Update(cmd())
+	m = next.(model)
+	if m.dupes.report == nil || len(m.dupes.report.Groups) != 2 {
+		t.Fatalf("duplicates view report = %+v, err %q", m.dupes.report, m.dupes.err)
+	}
+	if view := m.viewDupes(); !strings.Contains(view, filepath.Join(tmpDir, "b", "two.txt")) {
+		t.Errorf("view does not list the selected set's copies:\n%s", view)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	m = next.(model)
+	if view := m.viewDupes(); !m.dupes.byFolder || !strings.Contains(view, "Copies") {
+		t.Errorf("tab did not switch to folders:\n%s", view)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if next.(model).state != stateDone {
+		t.Errorf("esc did not return to the results screen")
+	}
+}
+
+func TestFindDuplicatesCancelled(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("same"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, nil); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := findDuplicatesIn(ctx, dbPath, dupeOptions{hashMissing: true}); !errors.Is(err, context.Canceled) {
+		t.Errorf("findDuplicatesIn() with a cancelled context = %v, want context.Canceled", err)
+	}
+
+	// Leaving the screen while hashing stops it, and returns to the results
+	// once the search has reported back
+	m := model{state: stateDone, dbPath: dbPath}
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	next, _ = next.(model).Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = next.(model)
+	if m.state != stateDupes || !m.dupes.stopping {
+		t.Fatalf("esc while hashing: state %v, stopping %v; want to wait on the duplicates screen", m.state, m.dupes.stopping)
+	}
+	next, _ = m.Update(cmd())
+	if m = next.(model); m.state != stateDone {
+		t.Errorf("after the stopped search reported back: state %v, want the results screen", m.state)
+	}
+}
+
+func TestVerifyCatalog(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"ok.txt":      "unchanged",
+		"rot.txt":     "original",
+		"edited.txt":  "before",
+		"gone.txt":    "here today",
+		"no-hash.txt": "skipped",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir, hash: true}, nil); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE files SET sha256 = NULL WHERE name = 'no-hash.txt'`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// Bit rot: same size and mtime, different bytes
+	rot := filepath.Join(tmpDir, "rot.txt")
+	info, err := os.Stat(rot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(rot, []byte("origXnal"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(rot, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	edited := filepath.Join(tmpDir, "edited.txt")
+	if err := os.WriteFile(edited, []byte("after, and longer"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(tmpDir, "gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := verifyCatalog(context.Background(), dbPath, verifyOptions{workers: 2})
+	if err != nil {
+		t.Fatalf("verifyCatalog() failed: %v", err)
+	}
+	if s.Total != 4 || s.Checked != 4 || s.OK != 1 || s.Modified != 1 || s.Corrupted != 1 || s.Missing != 1 || s.Errors != 0 {
+		t.Errorf("summary = %+v, want 4 checked: 1 ok, 1 modified, 1 corrupted, 1 missing", s)
+	}
+	if s.clean() {
+		t.Error("clean() = true with problems found")
+	}
+
+	db, err = openCatalogReadOnly(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	got := map[string]string{}
+	rows, err := db.Query(`SELECT abs_path, status FROM verify_results WHERE verify_run = ?`, s.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var path, status string
+		if err := rows.Scan(&path, &status); err != nil {
+			t.Fatal(err)
+		}
+		got[filepath.Base(path)] = status
+	}
+	rows.Close()
+	want := map[string]string{"ok.txt": verifyOK, "rot.txt": verifyCorrupted, "edited.txt": verifyModified, "gone.txt": verifyMissing}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("verify_results = %v, want %v", got, want)
+	}
+
+	// A cancelled verify records nothing it did not check
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s, err = verifyCatalog(ctx, dbPath, verifyOptions{workers: 1})
+	if err != nil {
+		t.Fatalf("verifyCatalog() failed: %v", err)
+	}
+	if !s.Cancelled || s.clean() {
+		t.Errorf("cancelled verify = %+v", s)
+	}
+	var recorded int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM verify_results WHERE verify_run = ?`, s.RunID).Scan(&recorded); err != nil {
+		t.Fatal(err)
+	}
+	if recorded != s.Checked {
+		t.Errorf("%d results recorded for %d checked files", recorded, s.Checked)
+	}
+
+	var stdout, stderr strings.Builder
+	if code := runCLI([]string{"verify", "--db", dbPath, "--quiet"}, &stdout, &stderr); code != exitPartial {
+		t.Errorf("verify exit code = %d, want %d (stderr: %s)", code, exitPartial, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "corrupted  "+rot) {
+		t.Errorf("verify output does not list the corrupted file:\n%s", stdout.String())
+	}
+}
+
+func TestRunCLI(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{"no command", nil, exitUsage, ""},
+		{"unknown command", []string{"frobnicate"}, exitUsage, ""},
+		{"missing root", []string{"scan", "--out", outDir}, exitUsage, ""},
+		{"missing root dir", []string{"scan", "--out", outDir, filepath.Join(tmpDir, "nope")}, exitUsage, ""},
+		{"bad symlink policy", []string{"scan", "--out", outDir, "--symlinks", "maybe", tmpDir}, exitUsage, ""},
+		{"bad size filter", []string{"scan", "--out", outDir, "--size", "huge", tmpDir}, exitUsage, ""},
+		{"scan", []string{"scan", "--out", outDir, "--hash", tmpDir}, exitOK, "files:     1"},
+		{"quiet scan", []string{"scan", "--out", outDir, "--quiet", "--root", tmpDir}, exitOK, ""},
+		{"export missing db", []string{"export", "--db", filepath.Join(tmpDir, "nope.db")}, exitFatal, ""},
+		{"export bad column", []string{"export", "--db", filepath.Join(outDir, "catalog.db"), "--columns", "nope"}, exitUsage, ""},
+		{"export", []string{"export", "--db", filepath.Join(outDir, "catalog.db"), "--format", "tsv", "--columns", "name,size"}, exitOK, "name\tsize\na.txt\t5\n"},
+		{"report without --html", []string{"report", "--db", filepath.Join(outDir, "catalog.db")}, exitUsage, ""},
+		{"report", []string{"report", "--db", filepath.Join(outDir, "catalog.db"), "--html", "-"}, exitOK, "<td class=\"path\">" + filepath.Join(tmpDir, "a.txt") + "</td>"},
+		{"dupes bad format", []string{"dupes", "--db", filepath.Join(outDir, "catalog.db"), "--format", "xml"}, exitUsage, ""},
+		{"dupes", []string{"dupes", "--db", filepath.Join(outDir, "catalog.db")}, exitOK, "duplicate sets: 0"},
+		{"verify", []string{"verify", "--db", filepath.Join(outDir, "catalog.db")}, exitOK, "ok:         1"},
+		{"verify bad format", []string{"verify", "--db", filepath.Join(outDir, "catalog.db"), "--format", "csv"}, exitUsage, ""},
+		{"dupes json", []string{"dupes", "--db", filepath.Join(outDir, "catalog.db"), "--format", "json"}, exitOK, `"group_count": 0`},
+		{"dupes to file", []string{"dupes", "--db", filepath.Join(outDir, "catalog.db"), "--out", filepath.Join(outDir, "dupes.json")}, exitOK, ""},
+		{"mismatches", []string{"mismatches", "--db", filepath.Join(outDir, "catalog.db")}, exitOK, "files sniffed: 0"},
+		{"mismatches to file", []string{"mismatches", "--db", filepath.Join(outDir, "catalog.db"), "--out", filepath.Join(outDir, "mismatches.csv")}, exitOK, ""},
+		{"export to file", []string{"export", "--db", filepath.Join(outDir, "catalog.db"), "--columns", "name", "--out", filepath.Join(outDir, "export.tsv")}, exitOK, ""},
+		{"report to file", []string{"report", "--db", filepath.Join(outDir, "catalog.db"), "--html", filepath.Join(outDir, "report.html")}, exitOK, ""},
+		{"mismatches bad format", []string{"mismatches", "--db", filepath.Join(outDir, "catalog.db"), "--format", "xml"}, exitUsage, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr strings.Builder
+			if code := runCLI(tt.args, &stdout, &stderr); code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d (stderr: %s)", code, tt.wantCode, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.wantOut) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout.String(), tt.wantOut)
+			}
+		})
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "catalog.db")); err != nil {
+		t.Errorf("catalog not written: %v", err)
+	}
+	// --out's extension picks the format
+	for name, want := range map[string]string{
+		"dupes.json":     `"group_count": 0`,
+		"mismatches.csv": "path,ext,size,expected,sniffed_mime\n",
+		"export.tsv":     "name\na.txt\n",
+		"report.html":    "<td class=\"path\">",
+	} {
+		if data, err := os.ReadFile(filepath.Join(outDir, name)); err != nil || !strings.Contains(string(data), want) {
+			t.Errorf("%s = %q, %v; want it to contain %q", name, data, err, want)
+		}
+	}
+}
+
+func TestRunCLIPartial(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// An ignore "file" that is a directory cannot be read
+	if err := os.Mkdir(filepath.Join(tmpDir, ignoreFileName), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr strings.Builder
+	code := runCLI([]string{"scan", "--out", t.TempDir(), tmpDir}, &stdout, &stderr)
+	if code != exitPartial {
+		t.Fatalf("exit code = %d, want %d (stderr: %s)", code, exitPartial, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "error: ignore") {
+		t.Errorf("stderr = %q, want the unreadable ignore file listed", stderr.String())
+	}
+}
+
+func TestRunCLIJSON(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(tmpDir, ignoreFileName), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr strings.Builder
+	code := runCLI([]string{"scan", "--json", "--out", t.TempDir(), tmpDir}, &stdout, &stderr)
+	if code != exitPartial {
+		t.Fatalf("exit code = %d, want %d (stderr: %s)", code, exitPartial, stderr.String())
+	}
+
+	var events []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		var ev map[string]any
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("line %q is not JSON: %v", line, err)
+		}
+		events = append(events, ev)
+	}
+	var kinds []string
+	for _, ev := range events {
+		kinds = append(kinds, ev["event"].(string))
+	}
+	if len(kinds) < 3 || kinds[0] != "started" || kinds[len(kinds)-1] != "summary" {
+		t.Fatalf("events = %v, want started ... summary", kinds)
+	}
+
+	runID := events[0]["run_id"]
+	var sawError bool
+	for _, ev := range events {
+		if ev["run_id"] != runID {
+			t.Errorf("%s event run_id = %v, want %v", ev["event"], ev["run_id"], runID)
+		}
+		if ev["event"] == "error" && ev["op"] == "ignore" {
+			sawError = true
+		}
+	}
+	if !sawError {
+		t.Errorf("no error event for the unreadable ignore file: %v", kinds)
+	}
+	summary := events[len(events)-1]
+	if summary["status"] != runCompleted || summary["files"] != float64(1) || summary["errors"] != float64(1) {
+		t.Errorf("summary = %v", summary)
+	}
+}
+
+func TestExportCatalog(t *testing.T) {
+	tmpDir := t.TempDir()
+	for name, content := range map[string]string{
+		"plain.pdf":          "pdf",
+		"comma, quote\".txt": "text",
+		"line\nbreak.docx":   "docx",
+	} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Skipf("cannot create %q: %v", name, err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, nil); err != nil {
+		t.Fatalf("scanAndPersist() failed: %v", err)
+	}
+
+	// Quoting round-trips through a CSV reader
+	var buf strings.Builder
+	n, err := exportCatalog(dbPath, &buf, exportOptions{format: "csv", columns: []string{"name", "size", "folder_mtime_utc"}, withFolders: true})
+	if err != nil {
+		t.Fatalf("exportCatalog() failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("exported %d rows, want 3", n)
+	}
+	records, err := csv.NewReader(strings.NewReader(buf.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if len(records) != 4 || strings.Join(records[0], ",") != "name,size,folder_mtime_utc" {
+		t.Fatalf("records = %q", records)
+	}
+	names := map[string]bool{}
+	for _, rec := range records[1:] {
+		names[rec[0]] = true
+		if rec[2] == "" {
+			t.Errorf("%q has no folder_mtime_utc", rec[0])
+		}
+	}
+	for _, want := range []string{"plain.pdf", "comma, quote\".txt", "line\nbreak.docx"} {
+		if !names[want] {
+			t.Errorf("export is missing %q: %q", want, records)
+		}
+	}
+
+	// TSV with a filter
+	buf.Reset()
+	n, err = exportCatalog(dbPath, &buf, exportOptions{format: "tsv", columns: []string{"name", "ext"}, where: "ext = '.pdf'"})
+	if err != nil {
+		t.Fatalf("exportCatalog(tsv) failed: %v", err)
+	}
+	if n != 1 || buf.String() != "name\text\nplain.pdf\t.pdf\n" {
+		t.Errorf("tsv export = %q (%d rows)", buf.String(), n)
+	}
+
+	// Unknown columns, folder columns without the join and writes are refused
+	for _, opts := range []exportOptions{
+		{columns: []string{"nope"}},
+		{columns: []string{"folder_mtime_utc"}},
+		{where: "1=1) ; DELETE FROM files; SELECT (1"},
+		{where: "abs_path IN (SELECT abs_path FROM files) AND (DELETE FROM files)"},
+	} {
+		if _, err := exportCatalog(dbPath, io.Discard, opts); err == nil {
+			t.Errorf("exportCatalog(%+v) succeeded, want an error", opts)
+		}
+	}
+	var count int
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.QueryRow(`SELECT COUNT(*) FROM files`).Scan(&count); err != nil || count != 3 {
+		t.Errorf("files after refused exports = %d (%v), want 3", count, err)
+	}
+}
+
+func TestExportFailureLeavesNoFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	if err := scanAndPersist(context.Background(), dbPath, scanOptions{root: tmpDir}, nil); err != nil {
+		t.Fatal(err)
+	}
+	outDir := t.TempDir()
+
+	// The condition only fails once the query runs
+	out := filepath.Join(outDir, "catalog.csv")
+	var stdout, stderr strings.Builder
+	if code := runCLI([]string{"export", "--db", dbPath, "--where", "nope = 1", "--out", out}, &stdout, &stderr); code != exitFatal {
+		t.Errorf("export with a bad --where: exit code %d, want %d", code, exitFatal)
+	}
+	if _, err := os.Stat(out); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("failed export left %s behind: %v", out, err)
+	}
+
+	out = filepath.Join(outDir, "catalog.xlsx")
+	if _, err := exportCatalogFile(filepath.Join(outDir, "nope.db"), out); err == nil {
+		t.Error("exportCatalogFile() of a missing catalog succeeded")
+	}
+	if _, err := os.Stat(out); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("failed export left %s behind: %v", out, err)
+	}
+}
+
+func TestOpenCatalogReadOnlyDoesNotMigrate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.txt")
+	dbDir := t.TempDir()
+	dbPath := filepath.Join(dbDir, "catalog.db")
+	writeBaselineCatalog(t, dbPath, file)
+	if os.Geteuid() != 0 {
+		// Readers must cope with a catalog they cannot write
+		if err := os.Chmod(dbPath, 0o444); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(dbDir, 0o555); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.Chmod(dbDir, 0o755) })
+	}
+
+	var buf strings.Builder
+	n, err := exportCatalog(dbPath, &buf, exportOptions{format: "csv"})
+	if err != nil {
+		t.Fatalf("exportCatalog() on a baseline catalog failed: %v", err)
+	}
+	if n != 1 || !strings.Contains(buf.String(), "a.txt") {
+		t.Errorf("exported %d rows: %q; want the one file", n, buf.String())
+	}
+	if _, err := findMismatchesIn(dbPath); err != nil {
+		t.Errorf("findMismatchesIn() on a baseline catalog failed: %v", err)
+	}
+	if results, _, err := searchCatalog(dbPath, searchQuery{name: "a"}, time.Now()); err != nil || len(results) != 1 {
+		t.Errorf("searchCatalog() on a baseline catalog = %d results, %v; want 1", len(results), err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	cols, err := tableColumns(db, "main", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'`).Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if len(cols) != 8 || tables != 2 {
+		t.Errorf("after reading: files has %d columns, catalog has %d tables; want the baseline 8 and 2", len(cols), tables)
+	}
+}
+
 // Benchmark tests
 func BenchmarkParseExtSet(b *testing.B) {
 	input := ".pdf,.docx,.txt,.xlsx,.pptx,.jpg,.png,.gif,.mp4,.avi"
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------- HTML report ----------
+
+// reportTopN is how many rows the report lists in its top-N tables.
+const reportTopN = 25
+
+// catalogReport holds everything the HTML report shows, read from a catalog.
+type catalogReport struct {
+	Database  string
+	Generated time.Time
+
+	// Latest scan run, if the catalog has one
+	Run        *reportRun
+	RunResults []reportRow
+
+	Files, Folders, Bytes int64
+	DeletedFiles          int64
+	HashedFiles           int64
+
+	Extensions     []reportTotal
+	LargestFiles   []reportFile
+	LargestFolders []reportTotal // including subfolders
+	Ages           []reportTotal
+
+	Duplicates      []duplicateGroup
+	DuplicateGroups int64
+	DuplicateWasted int64 // bytes beyond the first copy of each group
+
+	Errors     []reportError
+	ErrorCount int64
+}
+
+// reportRow and reportError carry resultRow and scanErrorRecord into the
+// template, which only sees exported fields.
+type reportRow struct {
+	Label, Value string
+	Alert        bool
+}
+
+type reportError struct {
+	Path, Op, Err string
+}
+
+// reportRun is the scan_runs row a report describes.
+type reportRun struct {
+	ID, Root, Status string
+	Started          time.Time
+	Finished         time.Time
+	Error            string
+}
+
+// reportTotal is a count and size for one group of files.
+type reportTotal struct {
+	Name         string
+	Files, Bytes int64
+}
+
+type reportFile struct {
+	Path     string
+	Size     int64
+	Modified time.Time
+}
+
+// reportAgeBuckets are the age histogram's upper bounds, youngest first.
+var reportAgeBuckets = []struct {
+	label         string
+	years, months int
+	days          int
+}{
+	{"Last 30 days", 0, 0, 30},
+	{"1–6 months", 0, 6, 0},
+	{"6–12 months", 1, 0, 0},
+	{"1–2 years", 2, 0, 0},
+	{"2–3 years", 3, 0, 0},
+	{"3–5 years", 5, 0, 0},
+	{"5–10 years", 10, 0, 0},
+}
+
+// buildCatalogReport reads the report for the catalog at dbPath. Ages are
+// measured from now.
+func buildCatalogReport(dbPath string, now time.Time) (*catalogReport, error) {
+	db, err := openCatalogReadOnly(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	r := &catalogReport{Database: dbPath, Generated: now}
+	if err := r.loadRun(db); err != nil {
+		return nil, err
+	}
+	if err := db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(size), 0), COUNT(NULLIF(sha256, ''))
+		FROM files WHERE deleted_at IS NULL
+	`).Scan(&r.Files, &r.Bytes, &r.HashedFiles); err != nil {
+		return nil, err
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM files WHERE deleted_at IS NOT NULL`).Scan(&r.DeletedFiles); err != nil {
+		return nil, err
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM folders WHERE deleted_at IS NULL`).Scan(&r.Folders); err != nil {
+		return nil, err
+	}
+
+	if r.Extensions, err = queryTotals(db, `
+		SELECT COALESCE(NULLIF(ext, ''), '(none)'), COUNT(*), COALESCE(SUM(size), 0)
+		FROM files WHERE deleted_at IS NULL GROUP BY 1 ORDER BY 3 DESC, 1 LIMIT ?
+	`, reportTopN); err != nil {
+		return nil, err
+	}
+	if err := r.loadLargestFiles(db); err != nil {
+		return nil, err
+	}
+	if err := r.loadLargestFolders(db); err != nil {
+		return nil, err
+	}
+	if err := r.loadAges(db, now); err != nil {
+		return nil, err
+	}
+	if r.HashedFiles > 0 {
+		dupes, err := findDuplicates(context.Background(), db, dupeOptions{})
+		if err != nil {
+			return nil, err
+		}
+		r.DuplicateGroups, r.DuplicateWasted = int64(len(dupes.Groups)), dupes.Wasted
+		r.Duplicates = dupes.Groups[:min(len(dupes.Groups), reportTopN)]
+	}
+	return r, nil
+}
+
+// loadRun reads the most recent scan run and its errors.
+func (r *catalogReport) loadRun(db *sql.DB) error {
+	var (
+		run               reportRun
+		started, finished sql.NullString
+		errText           sql.NullString
+		durationMs        sql.NullInt64
+		counts            [4]sql.NullInt64
+	)
+	err := db.QueryRow(`
+		SELECT id, root, status, started_utc, finished_utc, error, duration_ms, files, folders, bytes, errors
+		FROM scan_runs ORDER BY started_utc DESC, id DESC LIMIT 1
+	`).Scan(&run.ID, &run.Root, &run.Status, &started, &finished, &errText, &durationMs,
+		&counts[0], &counts[1], &counts[2], &counts[3])
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	run.Started, _ = time.Parse(time.RFC3339, started.String)
+	run.Finished, _ = time.Parse(time.RFC3339, finished.String)
+	run.Error = errText.String
+	r.Run = &run
+
+	s := stats{files: counts[0].Int64, folders: counts[1].Int64, bytes: counts[2].Int64, errors: counts[3].Int64}
+	for _, row := range s.resultRows(time.Duration(durationMs.Int64)*time.Millisecond, false) {
+		r.RunResults = append(r.RunResults, reportRow{Label: row.label, Value: row.value, Alert: row.alert})
+	}
+	r.ErrorCount = s.errors
+
+	rows, err := db.Query(`SELECT path, op, error FROM scan_errors WHERE run_id = ? ORDER BY id LIMIT ?`, run.ID, reportTopN*4)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e reportError
+		if err := rows.Scan(&e.Path, &e.Op, &e.Err); err != nil {
+			return err
+		}
+		r.Errors = append(r.Errors, e)
+	}
+	return rows.Err()
+}
+
+func (r *catalogReport) loadLargestFiles(db *sql.DB) error {
+	rows, err := db.Query(`
+		SELECT abs_path, size, mtime_utc FROM files
+		WHERE deleted_at IS NULL ORDER BY size DESC, abs_path LIMIT ?
+	`, reportTopN)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f reportFile
+		var mtime sql.NullString
+		if err := rows.Scan(&f.Path, &f.Size, &mtime); err != nil {
+			return err
+		}
+		f.Modified, _ = time.Parse(time.RFC3339, mtime.String)
+		r.LargestFiles = append(r.LargestFiles, f)
+	}
+	return rows.Err()
+}
+
+// loadLargestFolders ranks folders by the size of everything beneath them.
+func (r *catalogReport) loadLargestFolders(db *sql.DB) error {
+	tree, err := loadFolderTree(db)
+	if err != nil {
+		return err
+	}
+	all := make([]reportTotal, 0, len(tree.totals))
+	for p, t := range tree.totals {
+		all = append(all, reportTotal{Name: p, Files: t.files, Bytes: t.bytes})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Bytes != all[j].Bytes {
+			return all[i].Bytes > all[j].Bytes
+		}
+		return all[i].Name < all[j].Name
+	})
+	if len(all) > reportTopN {
+		all = all[:reportTopN]
+	}
+	r.LargestFolders = all
+	return nil
+}
+
+// loadAges builds the modification-age histogram.
+func (r *catalogReport) loadAges(db *sql.DB, now time.Time) error {
+	r.Ages = make([]reportTotal, len(reportAgeBuckets)+2)
+	bounds := make([]time.Time, len(reportAgeBuckets))
+	for i, b := range reportAgeBuckets {
+		r.Ages[i].Name = b.label
+		bounds[i] = now.AddDate(-b.years, -b.months, -b.days)
+	}
+	older, unknown := len(bounds), len(bounds)+1
+	r.Ages[older].Name = "Older"
+	r.Ages[unknown].Name = "Unknown"
+
+	rows, err := db.Query(`SELECT mtime_utc, COALESCE(size, 0) FROM files WHERE deleted_at IS NULL`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var mtime sql.NullString
+		var size int64
+		if err := rows.Scan(&mtime, &size); err != nil {
+			return err
+		}
+		i := unknown
+		if t, err := time.Parse(time.RFC3339, mtime.String); err == nil {
+			i = sort.Search(len(bounds), func(i int) bool { return !t.Before(bounds[i]) })
+		}
+		r.Ages[i].Files++
+		r.Ages[i].Bytes += size
+	}
+	if r.Ages[unknown].Files == 0 {
+		r.Ages = r.Ages[:unknown]
+	}
+	return rows.Err()
+}
+
+// queryTotals runs a query returning name, count and bytes rows, with a
+// LIMIT parameter (-1 for none).
+func queryTotals(db *sql.DB, query string, limit int) ([]reportTotal, error) {
+	rows, err := db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []reportTotal
+	for rows.Next() {
+		var t reportTotal
+		if err := rows.Scan(&t.Name, &t.Files, &t.Bytes); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// writeHTMLReport renders r as a single self-contained HTML page: styles
+// are inline and charts are plain CSS bars, so it works offline and can be
+// mailed as one attachment.
+func writeHTMLReport(w io.Writer, r *catalogReport) error {
+	return reportTemplate.Execute(w, r)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"size":  formatSize,
+	"count": func(n int64) string { return formatCount(n) },
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return "—"
+		}
+		return t.UTC().Format("2006-01-02 15:04 UTC")
+	},
+	// pct is part's share of whole, for bar widths
+	"pct": func(part, whole int64) string {
+		if whole <= 0 {
+			return "0"
+		}
+		return fmt.Sprintf("%.1f", float64(part)*100/float64(whole))
+	},
+	"maxBytes": func(ts []reportTotal) int64 {
+		var m int64
+		for _, t := range ts {
+			m = max(m, t.Bytes)
+		}
+		return m
+	},
+	"maxFiles": func(ts []reportTotal) int64 {
+		var m int64
+		for _, t := range ts {
+			m = max(m, t.Files)
+		}
+		return m
+	},
+	"more": func(shown int, total int64) int64 { return total - int64(shown) },
+}).Parse(reportHTML))
+
+// formatCount formats n with thousands separators.
+func formatCount(n int64) string {
+	s := fmt.Sprintf("%d", n)
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	var b strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	if neg {
+		return "-" + b.String()
+	}
+	return b.String()
+}
+
+const reportHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>SP Catalog report{{with .Run}} – {{.Root}}{{end}}</title>
+<style>
+body { font: 14px/1.45 -apple-system, "Segoe UI", Roboto, sans-serif; color: #1f2937; background: #f8fafc; margin: 0; }
+main { max-width: 1100px; margin: 0 auto; padding: 24px; }
+h1 { font-size: 24px; margin: 0 0 4px; }
+h2 { font-size: 18px; margin: 32px 0 8px; border-bottom: 2px solid #6366f1; padding-bottom: 4px; }
+.muted { color: #6b7280; }
+.cards { display: flex; flex-wrap: wrap; gap: 12px; margin: 16px 0; }
+.card { background: #fff; border: 1px solid #e5e7eb; border-radius: 8px; padding: 12px 16px; min-width: 140px; }
+.card b { display: block; font-size: 22px; color: #4f46e5; }
+table { width: 100%; border-collapse: collapse; background: #fff; border: 1px solid #e5e7eb; }
+th, td { text-align: left; padding: 6px 10px; border-bottom: 1px solid #f1f5f9; vertical-align: top; }
+th { background: #f1f5f9; font-weight: 600; }
+td.n, th.n { text-align: right; white-space: nowrap; }
+td.path { word-break: break-all; font-family: ui-monospace, Consolas, monospace; font-size: 12px; }
+td.bar { width: 30%; }
+.bar span { display: block; height: 12px; background: #818cf8; border-radius: 2px; }
+.alert { color: #dc2626; font-weight: 600; }
+.dupe td { border-bottom: none; }
+</style>
+</head>
+<body>
+<main>
+<h1>SP Catalog report</h1>
+<p class="muted">{{.Database}} · generated {{date .Generated}}</p>
+
+<div class="cards">
+<div class="card"><b>{{count .Files}}</b>files</div>
+<div class="card"><b>{{count .Folders}}</b>folders</div>
+<div class="card"><b>{{size .Bytes}}</b>total size</div>
+{{if .HashedFiles}}<div class="card"><b>{{size .DuplicateWasted}}</b>in duplicate copies</div>{{end}}
+{{if .ErrorCount}}<div class="card"><b class="alert">{{count .ErrorCount}}</b>unreadable paths</div>{{end}}
+{{if .DeletedFiles}}<div class="card"><b>{{count .DeletedFiles}}</b>deleted since cataloged</div>{{end}}
+</div>
+
+{{with .Run}}
+<h2>Latest scan</h2>
+<table>
+<tr><th>Root</th><td class="path">{{.Root}}</td></tr>
+<tr><th>Run ID</th><td>{{.ID}}</td></tr>
+<tr><th>Status</th><td{{if ne .Status "completed"}} class="alert"{{end}}>{{.Status}}{{with .Error}}: {{.}}{{end}}</td></tr>
+<tr><th>Started</th><td>{{date .Started}}</td></tr>
+<tr><th>Finished</th><td>{{date .Finished}}</td></tr>
+{{range $.RunResults}}<tr><th>{{.Label}}</th><td{{if .Alert}} class="alert"{{end}}>{{.Value}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h2>By extension</h2>
+{{$max := maxBytes .Extensions}}
+<table>
+<tr><th>Extension</th><th class="n">Files</th><th class="n">Size</th><th class="n">Share</th><th></th></tr>
+{{range .Extensions}}<tr><td>{{.Name}}</td><td class="n">{{count .Files}}</td><td class="n">{{size .Bytes}}</td><td class="n">{{pct .Bytes $.Bytes}}%</td><td class="bar"><span style="width: {{pct .Bytes $max}}%"></span></td></tr>
+{{else}}<tr><td colspan="5" class="muted">No files</td></tr>
+{{end}}</table>
+
+<h2>Age of files</h2>
+<p class="muted">By last modification.</p>
+{{$maxAge := maxFiles .Ages}}
+<table>
+<tr><th>Modified</th><th class="n">Files</th><th class="n">Size</th><th></th></tr>
+{{range .Ages}}<tr><td>{{.Name}}</td><td class="n">{{count .Files}}</td><td class="n">{{size .Bytes}}</td><td class="bar"><span style="width: {{pct .Files $maxAge}}%"></span></td></tr>
+{{end}}</table>
+
+<h2>Largest files</h2>
+<table>
+<tr><th>Path</th><th class="n">Size</th><th class="n">Modified</th></tr>
+{{range .LargestFiles}}<tr><td class="path">{{.Path}}</td><td class="n">{{size .Size}}</td><td class="n">{{date .Modified}}</td></tr>
+{{else}}<tr><td colspan="3" class="muted">No files</td></tr>
+{{end}}</table>
+
+<h2>Largest folders</h2>
+<p class="muted">Including everything in subfolders.</p>
+<table>
+<tr><th>Folder</th><th class="n">Files</th><th class="n">Size</th></tr>
+{{range .LargestFolders}}<tr><td class="path">{{.Name}}</td><td class="n">{{count .Files}}</td><td class="n">{{size .Bytes}}</td></tr>
+{{else}}<tr><td colspan="3" class="muted">No folders</td></tr>
+{{end}}</table>
+
+<h2>Duplicates</h2>
+{{if not .HashedFiles}}<p class="muted">The catalog has no checksums. Scan with hashing enabled to find duplicate files.</p>
+{{else if not .Duplicates}}<p class="muted">No duplicate files among {{count .HashedFiles}} hashed files.</p>
+{{else}}<p>Sets of identical files: {{count .DuplicateGroups}}. The extra copies take {{size .DuplicateWasted}}.</p>
+<table>
+<tr><th>Copies</th><th class="n">Size each</th><th class="n">Wasted</th></tr>
+{{range .Duplicates}}<tr class="dupe"><td class="path">{{range $i, $f := .Files}}{{if $i}}<br>{{end}}{{$f.Path}}{{end}}</td><td class="n">{{size .Size}}</td><td class="n">{{size .Wasted}}</td></tr>
+{{end}}</table>
+{{with more (len .Duplicates) .DuplicateGroups}}{{if gt . 0}}<p class="muted">… and {{count .}} more sets.</p>{{end}}{{end}}
+{{end}}
+
+<h2>Scan errors</h2>
+{{if not .Errors}}<p class="muted">No errors in the latest scan.</p>
+{{else}}<table>
+<tr><th>Operation</th><th>Path</th><th>Error</th></tr>
+{{range .Errors}}<tr><td>{{.Op}}</td><td class="path">{{.Path}}</td><td>{{.Err}}</td></tr>
+{{end}}</table>
+{{with more (len .Errors) .ErrorCount}}{{if gt . 0}}<p class="muted">… and {{count .}} more, in the scan_errors table.</p>{{end}}{{end}}
+{{end}}
+</main>
+</body>
+</html>
+`
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Values of scan_runs.status.
+const (
+	runRunning   = "running"
+	runCompleted = "completed"
+	runCancelled = "cancelled"
+	runFailed    = "failed"
+)
+
+// scanRun tracks the scan_runs row of the scan in progress.
+type scanRun struct {
+	id      string
+	root    string
+	started time.Time // when the run first started
+	session time.Time // when this process started or resumed it
+	priorMs int64     // time spent in earlier sessions of a resumed run
+
+	checkpoint string // resume after this path in walk order
+
+	files, folders, bytes, errors int64
+}
+
+// scanErrorRecord is one row of scan_errors.
+type scanErrorRecord struct {
+	path string
+	op   string
+	err  string
+}
+
+// runOptions is the JSON form of scanOptions stored in scan_runs.options, so
+// a catalog records exactly which settings produced it.
+type runOptions struct {
+	Root        string   `json:"root"`
+	ExtFilter   []string `json:"ext_filter,omitempty"`
+	Exclude     []string `json:"exclude,omitempty"`
+	SizeAtLeast int64    `json:"size_at_least,omitempty"`
+	SizeBelow   int64    `json:"size_below,omitempty"`
+	// Relative ages are resolved when the scan starts, so a run always
+	// records the absolute window it used
+	ModifiedSince  string   `json:"modified_since,omitempty"`
+	ModifiedBefore string   `json:"modified_before,omitempty"`
+	Hash           bool     `json:"hash"`
+	HashAlgorithms []string `json:"hash_algorithms,omitempty"`
+	HashWorkers    int      `json:"hash_workers,omitempty"`
+	Sniff          bool     `json:"sniff,omitempty"`
+	Incremental    bool     `json:"incremental"`
+	Symlinks       string   `json:"symlinks"`
+}
+
+func (o scanOptions) extList() []string {
+	exts := make([]string, 0, len(o.extFilter))
+	for e := range o.extFilter {
+		exts = append(exts, e)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+func (o scanOptions) toJSON() string {
+	ro := runOptions{
+		Root:        o.root,
+		ExtFilter:   o.extList(),
+		Exclude:     o.exclude,
+		SizeAtLeast: o.filter.sizeAtLeast,
+		SizeBelow:   o.filter.sizeBelow,
+		Hash:        o.hash,
+		Sniff:       o.sniff,
+		Incremental: o.incremental,
+		Symlinks:    o.symlinks.String(),
+	}
+	if o.hash {
+		ro.HashAlgorithms = o.algorithms()
+		ro.HashWorkers = o.workers()
+	}
+	if !o.filter.modifiedSince.IsZero() {
+		ro.ModifiedSince = o.filter.modifiedSince.UTC().Format(time.RFC3339)
+	}
+	if !o.filter.modifiedBefore.IsZero() {
+		ro.ModifiedBefore = o.filter.modifiedBefore.UTC().Format(time.RFC3339)
+	}
+	data, err := json.Marshal(ro)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// startRun records a new run as running.
+func startRun(db *sql.DB, run *scanRun, opts scanOptions) error {
+	hash := 0
+	if opts.hash {
+		hash = 1
+	}
+	_, err := db.Exec(`
+		INSERT INTO scan_runs(id, root, ext_filter, hash, options, started_utc, status)
+		VALUES(?, ?, ?, ?, ?, ?, ?)
+	`, run.id, run.root, strings.Join(opts.extList(), ","), hash, opts.toJSON(),
+		run.started.UTC().Format(time.RFC3339), runRunning)
+	return err
+}
+
+// resumeRun reopens the most recent run of root if it never completed and
+// has a checkpoint to continue from. It returns nil when there is nothing to
+// resume, in which case the caller starts a fresh run.
+func resumeRun(db *sql.DB, root string, opts scanOptions) (*scanRun, error) {
+	var (
+		run        scanRun
+		started    string
+		status     string
+		checkpoint sql.NullString
+		durationMs sql.NullInt64
+		counts     [4]sql.NullInt64
+	)
+	err := db.QueryRow(`
+		SELECT id, started_utc, status, checkpoint_path, duration_ms, files, folders, bytes, errors
+		FROM scan_runs WHERE root = ?
+		ORDER BY started_utc DESC, id DESC LIMIT 1
+	`, root).Scan(&run.id, &started, &status, &checkpoint, &durationMs, &counts[0], &counts[1], &counts[2], &counts[3])
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status == runCompleted || !checkpoint.Valid || checkpoint.String == "" {
+		return nil, nil
+	}
+
+	run.root = root
+	run.checkpoint = checkpoint.String
+	run.started, _ = time.Parse(time.RFC3339, started)
+	run.session = time.Now()
+	run.priorMs = durationMs.Int64
+	run.files, run.folders, run.bytes, run.errors = counts[0].Int64, counts[1].Int64, counts[2].Int64, counts[3].Int64
+
+	if _, err := db.Exec(`UPDATE scan_runs SET status = ?, options = ?, finished_utc = NULL, error = NULL WHERE id = ?`,
+		runRunning, opts.toJSON(), run.id); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// lastRunFiles returns the file count of the root's latest completed run
+// with the same extension filter, or 0 if there is none. scanAndPersist
+// uses it as the progress estimate instead of walking the tree twice.
+func lastRunFiles(db *sql.DB, root string, opts scanOptions) int64 {
+	var files sql.NullInt64
+	_ = db.QueryRow(`
+		SELECT files FROM scan_runs
+		WHERE root = ? AND ext_filter = ? AND status = ?
+		ORDER BY started_utc DESC, id DESC LIMIT 1
+	`, root, strings.Join(opts.extList(), ","), runCompleted).Scan(&files)
+	return files.Int64
+}
+
+// finishRun stores the outcome of a run. It is best effort: the scan result
+// is already committed, so a failure here must not mask scanErr.
+func finishRun(db *sql.DB, run *scanRun, scanErr error) {
+	status := runCompleted
+	var errText *string
+	switch {
+	case errors.Is(scanErr, context.Canceled):
+		status = runCancelled
+	case scanErr != nil:
+		status = runFailed
+		s := scanErr.Error()
+		errText = &s
+	}
+	finished := time.Now()
+	_, _ = db.Exec(`
+		UPDATE scan_runs SET finished_utc = ?, duration_ms = ?, files = ?, folders = ?, bytes = ?,
+		  errors = ?, status = ?, error = ?
+		WHERE id = ?
+	`, finished.UTC().Format(time.RFC3339), run.priorMs+finished.Sub(run.session).Milliseconds(),
+		run.files, run.folders, run.bytes, run.errors, status, errText, run.id)
+}
+
+// loadScanErrors returns up to limit errors recorded for runID, oldest first.
+func loadScanErrors(dbPath, runID string, limit int) ([]scanErrorRecord, error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT path, op, error FROM scan_errors WHERE run_id = ? ORDER BY id LIMIT ?`, runID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []scanErrorRecord
+	for rows.Next() {
+		var r scanErrorRecord
+		if err := rows.Scan(&r.path, &r.op, &r.err); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,1110 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------- scanning & DB ----------
+
+// Progress is reported every progressEvery files or every progressInterval,
+// whichever comes first, so large trees don't flood the UI with messages.
+const (
+	progressEvery    = 500
+	progressInterval = 150 * time.Millisecond
+)
+
+// batchSize is the number of rows written per transaction.
+const batchSize = 1000
+
+// maxHashWorkers caps the default worker count; hashing is I/O bound, so
+// more workers than this mostly adds seek contention.
+const maxHashWorkers = 8
+
+// progressReporter throttles scanner progress before handing it to send.
+type progressReporter struct {
+	send      func(progressMsg)
+	lastSent  time.Time
+	lastFiles int64
+}
+
+func (r *progressReporter) report(p progressMsg, force bool) {
+	if r.send == nil {
+		return
+	}
+	now := time.Now()
+	if !force && p.files-r.lastFiles < progressEvery && now.Sub(r.lastSent) < progressInterval {
+		return
+	}
+	r.lastSent = now
+	r.lastFiles = p.files
+	r.send(p)
+}
+
+// remainingTime extrapolates how long a scan that has processed done of
+// total files in elapsed still needs. It returns 0 when there is no basis
+// for an estimate.
+func remainingTime(done, total int64, elapsed time.Duration) time.Duration {
+	if done <= 0 || total <= done || elapsed <= 0 {
+		return 0
+	}
+	return time.Duration(float64(elapsed) * float64(total-done) / float64(done))
+}
+
+// scanOptions controls what scanAndPersist walks and how it records it.
+type scanOptions struct {
+	root        string
+	extFilter   map[string]struct{}
+	exclude     []string // gitignore-style patterns relative to root
+	filter      fileFilter
+	symlinks    symlinkPolicy
+	hash        bool
+	hashAlgos   []string // names from hashAlgorithms; empty means defaultHashAlgorithms
+	hashWorkers int      // <= 0 means defaultHashWorkers()
+	sniff       bool     // read each file's first bytes into files.sniffed_mime
+	incremental bool     // skip files whose size and mtime match the catalog
+	resume      bool     // continue the root's last unfinished run from its checkpoint
+
+	// Optional hooks for headless output, called on the writer goroutine
+	onStart func(runID, resumedFrom string)
+	onError func(scanErrorRecord)
+}
+
+func defaultHashWorkers() int {
+	n := runtime.NumCPU()
+	if n > maxHashWorkers {
+		n = maxHashWorkers
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// workers returns the size of the hashing pool. Without hashing there is
+// nothing to parallelise, so a single worker just forwards entries.
+func (o scanOptions) workers() int {
+	if !o.hash {
+		return 1
+	}
+	if o.hashWorkers > 0 {
+		return o.hashWorkers
+	}
+	return defaultHashWorkers()
+}
+
+// algorithms returns the hash algorithms to compute when hashing is on.
+func (o scanOptions) algorithms() []string {
+	if len(o.hashAlgos) == 0 {
+		return defaultHashAlgorithms
+	}
+	return o.hashAlgos
+}
+
+// includes reports whether a file passes the extension and size/date
+// filters. info is only consulted when a size or date filter is set.
+func (o scanOptions) includes(ext string, info func() (fs.FileInfo, error)) bool {
+	if len(o.extFilter) > 0 {
+		if _, ok := o.extFilter[ext]; !ok {
+			return false
+		}
+	}
+	if !o.filter.active() {
+		return true
+	}
+	fi, err := info()
+	return err == nil && o.filter.match(fi)
+}
+
+// fileStatus classifies a walked file against its existing catalog row.
+type fileStatus int
+
+const (
+	fileNew fileStatus = iota
+	fileChanged
+	fileUnchanged
+)
+
+// Values of files.hash_status. NULL means the row was written with hashing
+// off.
+const (
+	hashOK      = "ok"
+	hashError   = "error"   // the content could not be read; hash_error says why
+	hashSkipped = "skipped" // not a regular file, so there is no content to hash
+)
+
+// scanEntry is one walked path on its way from the walker, through the
+// hashing pool, to the DB writer.
+// Entries with err set are failures to record in scan_errors instead.
+type scanEntry struct {
+	seq    int64 // position in walk order, used for checkpoints
+	path   string
+	isDir  bool
+	info   fs.FileInfo
+	ext    string
+	sums   map[string]string // by algorithm name
+	status fileStatus
+
+	hashStatus string // files.hash_status; empty when hashing is off
+	hashErr    error  // why hashing failed, when hashStatus is hashError
+	sniffed    string // files.sniffed_mime; empty when sniffing is off or failed
+	sniffErr   error
+
+	entryType  string // files.entry_type: "file", "symlink" or "other"
+	linkTarget string // what a symlink points to, as stored in the link
+
+	op  string // operation that failed: "lstat", "readdir", "stat", "ignore", "hash" or "sniff"
+	err error
+}
+
+// hashable reports whether the entry is a regular file whose content can be
+// read. Links that are recorded rather than followed, and special files, are
+// not.
+func (e scanEntry) hashable() bool {
+	return !e.isDir && e.info != nil && e.info.Mode().IsRegular()
+}
+
+// doneErrorLimit is how many scan errors the done screen lists.
+const doneErrorLimit = 10
+
+func runScan(ctx context.Context, send func(tea.Msg), dbPath string, opts scanOptions) tea.Cmd {
+	return func() tea.Msg {
+		var last progressMsg
+		err := scanAndPersist(ctx, dbPath, opts, func(p progressMsg) {
+			last = p
+			send(p)
+		})
+		done := doneMsg{err: err}
+		if last.errors > 0 {
+			done.scanErrors, _ = loadScanErrors(dbPath, last.runID, doneErrorLimit)
+		}
+		return done
+	}
+}
+
+// scanAndPersist walks opts.root and upserts every folder and matching file
+// into the catalog at dbPath. The walker feeds a bounded pool of hashing
+// workers, and a single writer (the calling goroutine) drains their results
+// into batched transactions. When ctx is cancelled the walk stops, the
+// current batch is committed and ctx.Err() is returned. Each call is
+// recorded as a row in scan_runs.
+//
+// There is no separate counting pass: the walker counts files as it
+// discovers them, and the root's previous run supplies the estimate until
+// the walk finishes and the total is exact.
+func scanAndPersist(ctx context.Context, dbPath string, opts scanOptions, progress func(progressMsg)) (err error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := initSchema(db); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`PRAGMA journal_mode=WAL; PRAGMA synchronous=NORMAL; PRAGMA temp_store=MEMORY;`); err != nil {
+		return err
+	}
+
+	// Workers use this to classify files before deciding to hash them
+	var lookup *sql.Stmt
+	if opts.incremental {
+		// stored is 1 when every hash and the sniffed type this scan
+		// computes are in the row
+		present := []string{"1"}
+		if opts.hash {
+			for _, name := range opts.algorithms() {
+				a, _ := lookupHashAlgorithm(name)
+				present = append(present, a.column+" IS NOT NULL")
+			}
+		}
+		if opts.sniff {
+			present = append(present, "sniffed_mime IS NOT NULL")
+		}
+		stored := strings.Join(present, " AND ")
+		lookup, err = db.Prepare(`SELECT size, mtime_utc, ` + stored + `, entry_type, link_target FROM files WHERE abs_path = ?`)
+		if err != nil {
+			return err
+		}
+		defer lookup.Close()
+	}
+
+	root := filepath.Clean(opts.root)
+	var files, dirs, bytes, scanErrors, hashErrors, mismatches int64
+	var run *scanRun
+	if opts.resume {
+		if run, err = resumeRun(db, root, opts); err != nil {
+			return err
+		}
+	}
+	if run != nil {
+		// Counters continue from what the interrupted run committed
+		files, dirs, bytes, scanErrors = run.files, run.folders, run.bytes, run.errors
+	} else {
+		run = &scanRun{id: newRunID(), root: root, started: time.Now(), session: time.Now()}
+		if err := startRun(db, run, opts); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		run.files, run.folders, run.bytes, run.errors = files, dirs, bytes, scanErrors
+		finishRun(db, run, err)
+	}()
+	if opts.onStart != nil {
+		opts.onStart(run.id, run.checkpoint)
+	}
+
+	estimate := lastRunFiles(db, root, opts)
+	var discovered atomic.Int64 // files the walker has sent on
+	var walked atomic.Bool      // the walk finished, so discovered is exact
+	discovered.Store(files)
+
+	w := &catalogWriter{db: db, runID: run.id, checkpoint: &checkpointTracker{done: map[int64]string{}},
+		counts: func() (int64, int64, int64, int64) { return files, dirs, bytes, scanErrors }}
+	if err := w.begin(); err != nil {
+		return err
+	}
+
+	// walkCtx also stops the walker when the writer fails
+	walkCtx, stopWalk := context.WithCancel(ctx)
+	defer stopWalk()
+
+	workers := opts.workers()
+	jobs := make(chan scanEntry, workers*4)
+	results := make(chan scanEntry, workers*4)
+
+	var errWalk error
+	var seq int64
+	emit := func(e scanEntry) error {
+		e.seq = seq
+		seq++
+		select {
+		case jobs <- e:
+			return nil
+		case <-walkCtx.Done():
+			return walkCtx.Err()
+		}
+	}
+	ignore := newIgnoreMatcher(root, opts.exclude)
+	// pruned reports directories that are skipped without being read:
+	// excluded ones, and those a resumed run already committed except the
+	// checkpoint's ancestors
+	pruned := func(p string, d fs.DirEntry) bool {
+		if ignore.excluded(p, true) {
+			return true
+		}
+		return run.checkpoint != "" && walkOrderCompare(p, run.checkpoint) <= 0 && !isWithin(p, run.checkpoint)
+	}
+	var follow func(string, fs.DirEntry) fs.DirEntry
+	if opts.symlinks == linkFollow {
+		follow = newLinkFollower().follow
+	}
+	go func() {
+		defer close(jobs)
+		errWalk = parallelWalkDir(walkCtx, root, maxDirReaders, pruned, follow, func(p string, d os.DirEntry, walkErr error) error {
+			if err := walkCtx.Err(); err != nil {
+				return err
+			}
+			if d != nil && walkErr == nil && isLink(d.Type()) && opts.symlinks == linkIgnore {
+				return nil
+			}
+			if d != nil && d.IsDir() && walkErr == nil && pruned(p, d) {
+				return fs.SkipDir
+			}
+			if d != nil && !d.IsDir() && ignore.excluded(p, false) {
+				return nil
+			}
+			// A directory's rules must be loaded before its children are
+			// visited, including directories a resumed run skips over
+			var ignoreErr error
+			if d != nil && d.IsDir() && walkErr == nil {
+				ignoreErr = ignore.loadDir(p)
+			}
+			// Skip everything else the resumed run already committed
+			if run.checkpoint != "" && walkOrderCompare(p, run.checkpoint) <= 0 {
+				return nil
+			}
+			var e scanEntry
+			if walkErr != nil {
+				// d is the directory whose listing failed, or nil when
+				// the root itself could not be read
+				e = scanEntry{path: p, op: "lstat", err: walkErr}
+				if d != nil && d.IsDir() {
+					e.op = "readdir"
+				}
+			} else if info, err := d.Info(); err != nil {
+				e = scanEntry{path: p, op: "stat", err: err}
+			} else {
+				e = scanEntry{path: p, isDir: d.IsDir() && !isLink(d.Type()), info: info}
+				if !e.isDir {
+					e.entryType = entryTypeOf(info.Mode())
+				}
+				if e.entryType == entrySymlink {
+					e.linkTarget, _ = os.Readlink(p)
+					// Followed file links are cataloged with their
+					// target's metadata, and hashed like any other file
+					if opts.symlinks == linkFollow {
+						if target, err := os.Stat(p); err == nil && !target.IsDir() {
+							e.info = target
+						}
+					}
+				}
+			}
+			if e.err == nil && !e.isDir {
+				e.ext = strings.ToLower(filepath.Ext(p))
+				info := e.info
+				if !opts.includes(e.ext, func() (fs.FileInfo, error) { return info, nil }) {
+					return nil
+				}
+			}
+
+			if e.err == nil && !e.isDir {
+				discovered.Add(1)
+			}
+			if err := emit(e); err != nil {
+				return err
+			}
+			if ignoreErr != nil {
+				return emit(scanEntry{path: filepath.Join(p, ignoreFileName), op: "ignore", err: ignoreErr})
+			}
+			return nil
+		})
+		walked.Store(errWalk == nil)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range jobs {
+				// Once stopped, drop queued work instead of hashing it
+				if walkCtx.Err() != nil {
+					continue
+				}
+				if e.err != nil {
+					results <- e
+					continue
+				}
+				if lookup != nil && !e.isDir {
+					e.status = classifyFile(lookup, e)
+				}
+				if opts.sniff && e.hashable() && e.status != fileUnchanged {
+					e.sniffed, e.sniffErr = sniffFile(e.path)
+				}
+				if opts.hash && !e.isDir && e.status != fileUnchanged {
+					if !e.hashable() {
+						e.hashStatus = hashSkipped
+					} else if sums, err := hashFileWith(e.path, opts.algorithms()); err != nil {
+						e.hashStatus, e.hashErr = hashError, err
+					} else {
+						e.sums, e.hashStatus = sums, hashOK
+					}
+				}
+				results <- e
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var newFiles, changedFiles, unchangedFiles int64
+	var deletedFiles, deletedFolders int64
+	reporter := &progressReporter{send: progress}
+	current := func(last string) progressMsg {
+		// Without a previous run the total stays unknown until the walk
+		// ends; with one, it grows if this walk has already found more
+		found, final := discovered.Load(), walked.Load()
+		total := estimate
+		if final || (total > 0 && found > total) {
+			total = found
+		}
+		return progressMsg{
+			runID: run.id, resumedFrom: run.checkpoint,
+			files: files, folders: dirs, bytes: bytes, last: last,
+			estimatedTotal: total, discovered: found, totalFinal: final,
+			newFiles: newFiles, changedFiles: changedFiles, unchangedFiles: unchangedFiles,
+			deletedFiles: deletedFiles, deletedFolders: deletedFolders,
+			errors: scanErrors, hashErrors: hashErrors, mismatches: mismatches,
+		}
+	}
+
+	// Keep draining after a write error so the workers can exit
+	var errWrite error
+	for e := range results {
+		if errWrite != nil {
+			continue
+		}
+		w.checkpoint.complete(e.seq, e.path)
+		if e.err != nil {
+			errWrite = w.writeError(e)
+			if errWrite == nil {
+				scanErrors++
+				if opts.onError != nil {
+					opts.onError(scanErrorRecord{path: e.path, op: e.op, err: e.err.Error()})
+				}
+			}
+		} else if e.isDir {
+			errWrite = w.writeFolder(e)
+			if errWrite == nil {
+				dirs++
+			}
+		} else {
+			if e.status == fileUnchanged {
+				errWrite = w.touchFile(e)
+			} else {
+				errWrite = w.writeFile(e)
+			}
+			// A failed hash is also a scan error, so it is listed with the rest
+			if errWrite == nil && e.hashErr != nil {
+				errWrite = w.writeError(scanEntry{path: e.path, op: "hash", err: e.hashErr})
+				if errWrite == nil {
+					scanErrors++
+					hashErrors++
+					if opts.onError != nil {
+						opts.onError(scanErrorRecord{path: e.path, op: "hash", err: e.hashErr.Error()})
+					}
+				}
+			}
+			// A file that also failed to hash is reported once, above
+			if errWrite == nil && e.sniffErr != nil && e.hashErr == nil {
+				errWrite = w.writeError(scanEntry{path: e.path, op: "sniff", err: e.sniffErr})
+				if errWrite == nil {
+					scanErrors++
+					if opts.onError != nil {
+						opts.onError(scanErrorRecord{path: e.path, op: "sniff", err: e.sniffErr.Error()})
+					}
+				}
+			}
+			if errWrite == nil && contentMismatch(e.ext, e.sniffed) {
+				mismatches++
+			}
+			if errWrite == nil {
+				files++
+				bytes += e.info.Size()
+				if opts.incremental {
+					switch e.status {
+					case fileNew:
+						newFiles++
+					case fileChanged:
+						changedFiles++
+					case fileUnchanged:
+						unchangedFiles++
+					}
+				}
+			}
+		}
+		if errWrite == nil {
+			errWrite = w.rotate()
+		}
+		if errWrite != nil {
+			stopWalk()
+			continue
+		}
+		reporter.report(current(e.path), false)
+	}
+
+	if errWrite != nil {
+		w.rollback()
+		return errWrite
+	}
+	// Once ctx is cancelled the workers drop queued files, so the run is
+	// incomplete even when the walk itself had already finished
+	cancelled := ctx.Err() != nil
+	if errWalk != nil && !cancelled {
+		w.rollback()
+		return errWalk
+	}
+	// A cancelled scan still commits what it has, so the summary matches the DB
+	if err := w.commit(); err != nil {
+		return err
+	}
+
+	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_files_ext ON files(ext);`)
+	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_files_folder ON files(folder_path);`)
+	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_files_mtime ON files(mtime_utc);`)
+
+	// Only a complete walk knows which rows it did not see
+	if !cancelled {
+		deletedFiles, deletedFolders, err = markDeleted(db, root, run.id)
+		if err != nil {
+			return err
+		}
+	}
+
+	reporter.report(current(""), true)
+	if cancelled {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// classifyFile compares a walked file with its catalog row. A file is only
+// unchanged when size, mtime, entry type and link target match and, if
+// hashing or sniffing is on, its hashes and sniffed type are already stored;
+// otherwise it is re-cataloged and counted as changed. Files whose hash
+// failed have none stored, so an incremental scan retries them. lookup tells
+// whether what this scan computes is stored.
+func classifyFile(lookup *sql.Stmt, e scanEntry) fileStatus {
+	var size int64
+	var mtime string
+	var stored bool
+	var entryType, linkTarget sql.NullString
+	err := lookup.QueryRow(e.path).Scan(&size, &mtime, &stored, &entryType, &linkTarget)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fileNew
+	}
+	if err != nil {
+		return fileChanged
+	}
+	if size != e.info.Size() || mtime != e.info.ModTime().UTC().Format(time.RFC3339) {
+		return fileChanged
+	}
+	if entryType.String != e.entryType || linkTarget.String != e.linkTarget {
+		return fileChanged
+	}
+	if e.hashable() && !stored {
+		return fileChanged
+	}
+	return fileUnchanged
+}
+
+// newRunID returns a sortable identifier for one scan run.
+func newRunID() string {
+	return time.Now().UTC().Format("20060102T150405.000000000Z")
+}
+
+// markDeleted flags catalog rows under root that the run identified by
+// runID did not see. A row is only flagged once Lstat confirms the path is
+// gone, so files skipped by filters are never mistaken for deletions.
+func markDeleted(db *sql.DB, root, runID string) (files, folders int64, err error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	files, err = markDeletedIn(db, "files", "abs_path", root, runID, now)
+	if err != nil {
+		return 0, 0, err
+	}
+	folders, err = markDeletedIn(db, "folders", "path", root, runID, now)
+	if err != nil {
+		return files, 0, err
+	}
+	return files, folders, nil
+}
+
+func markDeletedIn(db *sql.DB, table, pathCol, root, runID, now string) (int64, error) {
+	// substr() instead of LIKE: '%' and '_' are legal in file names
+	prefix := strings.TrimSuffix(root, string(filepath.Separator)) + string(filepath.Separator)
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT %[1]s FROM %[2]s
+		WHERE deleted_at IS NULL AND (seen_run IS NULL OR seen_run <> ?)
+		  AND (%[1]s = ? OR substr(%[1]s, 1, length(?)) = ?)
+	`, pathCol, table), runID, root, prefix, prefix)
+	if err != nil {
+		return 0, err
+	}
+	var gone []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if _, err := os.Lstat(p); errors.Is(err, fs.ErrNotExist) {
+			gone = append(gone, p)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(gone) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf(`UPDATE %s SET deleted_at = ?, deleted_run = ? WHERE %s = ?`, table, pathCol))
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	for _, p := range gone {
+		if _, err := stmt.Exec(now, runID, p); err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(gone)), nil
+}
+
+// countDeleted returns how many rows purgeDeleted would remove.
+func countDeleted(dbPath string) (files, folders int64, err error) {
+	db, err := openCatalogReadOnly(dbPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer db.Close()
+	err = db.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM files WHERE deleted_at IS NOT NULL),
+		(SELECT COUNT(*) FROM folders WHERE deleted_at IS NOT NULL)`).Scan(&files, &folders)
+	return files, folders, err
+}
+
+// purgeDeleted permanently removes rows flagged as deleted from the catalog.
+func purgeDeleted(dbPath string) (files, folders int64, err error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer db.Close()
+	if err := initSchema(db); err != nil {
+		return 0, 0, err
+	}
+
+	res, err := db.Exec(`DELETE FROM files WHERE deleted_at IS NOT NULL`)
+	if err != nil {
+		return 0, 0, err
+	}
+	files, _ = res.RowsAffected()
+	res, err = db.Exec(`DELETE FROM folders WHERE deleted_at IS NOT NULL`)
+	if err != nil {
+		return files, 0, err
+	}
+	folders, _ = res.RowsAffected()
+	return files, folders, nil
+}
+
+// walkOrderCompare orders two paths the way filepath.WalkDir visits them: a
+// directory before its contents, and siblings by name. It returns a negative
+// number, zero or a positive number like strings.Compare.
+func walkOrderCompare(a, b string) int {
+	ap := strings.Split(filepath.ToSlash(filepath.Clean(a)), "/")
+	bp := strings.Split(filepath.ToSlash(filepath.Clean(b)), "/")
+	for i := 0; i < len(ap) && i < len(bp); i++ {
+		if c := strings.Compare(ap[i], bp[i]); c != 0 {
+			return c
+		}
+	}
+	return len(ap) - len(bp)
+}
+
+// isWithin reports whether p is dir itself or lies beneath it.
+func isWithin(dir, p string) bool {
+	dir, p = filepath.Clean(dir), filepath.Clean(p)
+	if dir == p {
+		return true
+	}
+	return strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// checkpointTracker follows how much of the walk has reached the writer.
+// Workers finish out of order, so it records the last path of the
+// contiguous prefix of walk sequence numbers seen so far.
+type checkpointTracker struct {
+	next int64
+	done map[int64]string
+	last string
+}
+
+func (c *checkpointTracker) complete(seq int64, path string) {
+	c.done[seq] = path
+	for {
+		p, ok := c.done[c.next]
+		if !ok {
+			return
+		}
+		c.last = p
+		delete(c.done, c.next)
+		c.next++
+	}
+}
+
+// catalogWriter batches folder and file upserts into transactions of
+// batchSize rows. It is only ever used from the writer goroutine. Every row
+// it writes or touches is stamped with runID as the run that last saw it,
+// and every commit also stores the run's checkpoint.
+type catalogWriter struct {
+	db         *sql.DB
+	runID      string
+	checkpoint *checkpointTracker
+	// counts returns the run's totals so far, saved with each checkpoint
+	counts     func() (files, folders, bytes, errors int64)
+	tx         *sql.Tx
+	folderStmt *sql.Stmt
+	fileStmt   *sql.Stmt
+	touchStmt  *sql.Stmt
+	errStmt    *sql.Stmt
+	batch      int
+}
+
+func (w *catalogWriter) begin() error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	folderStmt, err := tx.Prepare(`
+		INSERT INTO folders(path, parent_path, mtime_utc, seen_run)
+		VALUES(?, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET
+		  mtime_utc=excluded.mtime_utc, seen_run=excluded.seen_run,
+		  deleted_at=NULL, deleted_run=NULL
+	`)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	fileStmt, err := tx.Prepare(fileUpsertSQL())
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	touchStmt, err := tx.Prepare(`UPDATE files SET seen_run=?, deleted_at=NULL, deleted_run=NULL WHERE abs_path=?`)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	errStmt, err := tx.Prepare(`INSERT INTO scan_errors(run_id, path, op, error, at_utc) VALUES(?, ?, ?, ?, ?)`)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	w.tx, w.folderStmt, w.fileStmt, w.touchStmt, w.errStmt, w.batch = tx, folderStmt, fileStmt, touchStmt, errStmt, 0
+	return nil
+}
+
+// commit ends the current transaction; statements prepared on it close too.
+// The checkpoint and the running totals are written in the same transaction
+// as the rows they cover, so a run resumed after a crash starts from both.
+func (w *catalogWriter) commit() error {
+	if w.checkpoint != nil && w.checkpoint.last != "" {
+		var files, folders, bytes, errs int64
+		if w.counts != nil {
+			files, folders, bytes, errs = w.counts()
+		}
+		if _, err := w.tx.Exec(`UPDATE scan_runs SET checkpoint_path = ?, checkpoint_utc = ?, files = ?, folders = ?, bytes = ?, errors = ? WHERE id = ?`,
+			w.checkpoint.last, time.Now().UTC().Format(time.RFC3339), files, folders, bytes, errs, w.runID); err != nil {
+			_ = w.tx.Rollback()
+			return err
+		}
+	}
+	return w.tx.Commit()
+}
+
+func (w *catalogWriter) rollback() {
+	_ = w.tx.Rollback()
+}
+
+// rotate commits and starts a new transaction once the batch is full. The
+// writer calls it between entries, once an entry's rows and counts are
+// both in, so a checkpoint never covers an entry its totals miss.
+func (w *catalogWriter) rotate() error {
+	if w.batch < batchSize {
+		return nil
+	}
+	if err := w.commit(); err != nil {
+		return err
+	}
+	return w.begin()
+}
+
+func (w *catalogWriter) writeFolder(e scanEntry) error {
+	parent := filepath.Dir(e.path)
+	if parent == e.path {
+		parent = ""
+	}
+	mtime := e.info.ModTime().UTC().Format(time.RFC3339)
+	if _, err := w.folderStmt.Exec(e.path, parent, mtime, w.runID); err != nil {
+		return err
+	}
+	w.batch++
+	return nil
+}
+
+func (w *catalogWriter) writeFile(e scanEntry) error {
+	dir := filepath.Dir(e.path)
+	name := filepath.Base(e.path)
+	mtime := e.info.ModTime().UTC().Format(time.RFC3339)
+	mime := detectMIME(e.ext)
+	if e.entryType == entrySymlink && !e.hashable() {
+		mime = "inode/symlink" // the link itself, not its target
+	}
+	var target *string
+	if e.linkTarget != "" {
+		target = &e.linkTarget
+	}
+	var hashStatus, hashErr *string
+	if e.hashStatus != "" {
+		hashStatus = &e.hashStatus
+	}
+	if e.hashErr != nil {
+		msg := e.hashErr.Error()
+		hashErr = &msg
+	}
+	args := []any{e.path, dir, name, e.ext, e.info.Size(), mtime, mime}
+	for _, a := range hashAlgorithms {
+		if sum, ok := e.sums[a.name]; ok {
+			args = append(args, sum)
+		} else {
+			args = append(args, nil)
+		}
+	}
+	args = append(args, hashStatus, hashErr, nullIfEmpty(e.sniffed), w.runID, e.entryType, target)
+	if _, err := w.fileStmt.Exec(args...); err != nil {
+		return err
+	}
+	w.batch++
+	return nil
+}
+
+// fileUpsertSQL inserts or updates one files row, with a column per hash
+// algorithm. A hash computed by this scan replaces the stored one. A stored
+// hash is kept only while size and mtime still match, since it describes
+// the content it was computed from, and never when hashing just failed.
+// The sniffed type is kept or replaced the same way.
+func fileUpsertSQL() string {
+	same := "files.size IS excluded.size AND files.mtime_utc IS excluded.mtime_utc"
+	cols := []string{"abs_path", "folder_path", "name", "ext", "size", "mtime_utc", "mime"}
+	var sets []string
+	for _, a := range hashAlgorithms {
+		cols = append(cols, a.column)
+		sets = append(sets, fmt.Sprintf(`%[1]s=CASE WHEN excluded.%[1]s IS NOT NULL THEN excluded.%[1]s
+		    WHEN excluded.hash_status IN ('%[2]s', '%[3]s') THEN NULL WHEN %[4]s THEN files.%[1]s END`, a.column, hashError, hashSkipped, same))
+	}
+	for _, col := range []string{"hash_status", "hash_error"} {
+		sets = append(sets, fmt.Sprintf(`%[1]s=CASE WHEN excluded.hash_status IS NOT NULL THEN excluded.%[1]s
+		    WHEN %[2]s THEN files.%[1]s END`, col, same))
+	}
+	sets = append(sets, fmt.Sprintf(`sniffed_mime=CASE WHEN excluded.sniffed_mime IS NOT NULL THEN excluded.sniffed_mime
+		    WHEN %s THEN files.sniffed_mime END`, same))
+	cols = append(cols, "hash_status", "hash_error", "sniffed_mime", "seen_run", "entry_type", "link_target")
+	return `
+		INSERT INTO files(` + strings.Join(cols, ", ") + `)
+		VALUES(` + strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ") + `)
+		ON CONFLICT(abs_path) DO UPDATE SET
+		  size=excluded.size, mtime_utc=excluded.mtime_utc, mime=excluded.mime,
+		  ` + strings.Join(sets, ",\n\t\t  ") + `,
+		  entry_type=excluded.entry_type, link_target=excluded.link_target,
+		  seen_run=excluded.seen_run, deleted_at=NULL, deleted_run=NULL
+	`
+}
+
+// writeError records a walk or stat failure for the current run.
+func (w *catalogWriter) writeError(e scanEntry) error {
+	at := time.Now().UTC().Format(time.RFC3339)
+	if _, err := w.errStmt.Exec(w.runID, e.path, e.op, e.err.Error(), at); err != nil {
+		return err
+	}
+	w.batch++
+	return nil
+}
+
+// touchFile records that an unchanged file was seen without rewriting it.
+func (w *catalogWriter) touchFile(e scanEntry) error {
+	if _, err := w.touchStmt.Exec(w.runID, e.path); err != nil {
+		return err
+	}
+	w.batch++
+	return nil
+}
+
+func initSchema(db *sql.DB) error {
+	ddl := `
+CREATE TABLE IF NOT EXISTS folders (
+	path TEXT PRIMARY KEY,
+	parent_path TEXT,
+	mtime_utc TEXT,
+	seen_run TEXT,
+	deleted_at TEXT,
+	deleted_run TEXT
+);
+CREATE TABLE IF NOT EXISTS scan_runs (
+	id           TEXT PRIMARY KEY,
+	root         TEXT NOT NULL,
+	ext_filter   TEXT,
+	hash         INTEGER,
+	options      TEXT,
+	started_utc  TEXT NOT NULL,
+	finished_utc TEXT,
+	duration_ms  INTEGER,
+	files        INTEGER,
+	folders      INTEGER,
+	bytes        INTEGER,
+	status       TEXT NOT NULL,
+	error        TEXT,
+	errors       INTEGER,
+	checkpoint_path TEXT,
+	checkpoint_utc  TEXT
+);
+CREATE TABLE IF NOT EXISTS scan_errors (
+	id      INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_id  TEXT NOT NULL,
+	path    TEXT NOT NULL,
+	op      TEXT NOT NULL,
+	error   TEXT NOT NULL,
+	at_utc  TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_scan_errors_run ON scan_errors(run_id);
+CREATE TABLE IF NOT EXISTS files (
+	abs_path    TEXT PRIMARY KEY,
+	folder_path TEXT NOT NULL,
+	name        TEXT NOT NULL,
+	ext         TEXT,
+	size        INTEGER,
+	mtime_utc   TEXT,
+	mime        TEXT,
+	sha256      TEXT,
+	sha1        TEXT,
+	md5         TEXT,
+	quickxor    TEXT,
+	hash_status TEXT,
+	hash_error  TEXT,
+	sniffed_mime TEXT,
+	seen_run    TEXT,
+	deleted_at  TEXT,
+	deleted_run TEXT,
+	entry_type  TEXT,
+	link_target TEXT
+);
+CREATE TABLE IF NOT EXISTS verify_results (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	verify_run      TEXT NOT NULL,
+	abs_path        TEXT NOT NULL,
+	status          TEXT NOT NULL,
+	expected_sha256 TEXT,
+	actual_sha256   TEXT,
+	size            INTEGER,
+	mtime_utc       TEXT,
+	error           TEXT,
+	checked_utc     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_verify_results_run ON verify_results(verify_run);
+`
+	if _, err := db.Exec(ddl); err != nil {
+		return err
+	}
+
+	// Catalogs written by older versions lack the newer columns
+	for table, cols := range map[string][]string{
+		"folders":   {"seen_run TEXT", "deleted_at TEXT", "deleted_run TEXT"},
+		"files":     {"seen_run TEXT", "deleted_at TEXT", "deleted_run TEXT", "entry_type TEXT", "link_target TEXT", "hash_status TEXT", "hash_error TEXT", "sha1 TEXT", "md5 TEXT", "quickxor TEXT", "sniffed_mime TEXT"},
+		"scan_runs": {"errors INTEGER", "checkpoint_path TEXT", "checkpoint_utc TEXT"},
+	} {
+		if err := ensureColumns(db, table, cols); err != nil {
+			return err
+		}
+	}
+	// Indexes on migrated columns can only be created once they exist
+	_, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_files_seen_run ON files(seen_run)`)
+	return err
+}
+
+// ensureColumns adds any of cols ("name TYPE") missing from table.
+func ensureColumns(db *sql.DB, table string, cols []string) error {
+	existing, err := tableColumns(db, "main", table)
+	if err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	for _, c := range existing {
+		have[c.name] = true
+	}
+
+	for _, col := range cols {
+		name := strings.Fields(col)[0]
+		if have[name] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s`, table, col)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableColumn is one column as PRAGMA table_info reports it.
+type tableColumn struct {
+	name, typ string
+}
+
+// tableColumns lists the columns of table in schema ("main" or "temp"), in
+// order. A table that does not exist has none.
+func tableColumns(db *sql.DB, schema, table string) ([]tableColumn, error) {
+	rows, err := db.Query(fmt.Sprintf(`PRAGMA %s.table_info(%s)`, schema, table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cols []tableColumn
+	for rows.Next() {
+		var (
+			cid     int
+			c       tableColumn
+			notNull int
+			dflt    sql.NullString
+			pk      int
+		)
+		if err := rows.Scan(&cid, &c.name, &c.typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		cols = append(cols, c)
+	}
+	return cols, rows.Err()
+}
+
+func parseExtSet(s string) map[string]struct{} {
+	m := map[string]struct{}{}
+	if s == "" {
+		return m
+	}
+	for _, e := range strings.Split(s, ",") {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" {
+			continue
+		}
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		m[e] = struct{}{}
+	}
+	return m
+}
+
+func detectMIME(ext string) string {
+	if ext == ".msg" {
+		return "application/vnd.ms-outlook"
+	}
+	mt := mime.TypeByExtension(ext)
+	if mt != "" {
+		return mt
+	}
+	return "application/octet-stream"
+}
+
+// hashFile returns the hex SHA256 of the file's content. A read that fails
+// part way returns the error, never the hash of the partial stream.
+func hashFile(path string) (string, error) {
+	sums, err := hashFileWith(path, []string{"sha256"})
+	if err != nil {
+		return "", err
+	}
+	return sums["sha256"], nil
+}
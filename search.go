@@ -0,0 +1,319 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------- catalog search ----------
+
+// searchLimit caps how many matches a search shows.
+const searchLimit = 200
+
+// searchDebounce is how long typing must pause before a query runs.
+const searchDebounce = 150 * time.Millisecond
+
+// Search fields, in focus order.
+const (
+	searchName = iota
+	searchExt
+	searchSize
+	searchModified
+	searchFieldCount
+)
+
+// searchQuery is what the search fields ask for. Empty fields match
+// everything.
+type searchQuery struct {
+	name     string // case-insensitive substring of the file name
+	ext      string // extension list, as in the form
+	size     string // size filter terms, as in the form
+	modified string // modified filter terms, as in the form
+}
+
+// searchResult is one matching file.
+type searchResult struct {
+	path     string
+	name     string
+	ext      string
+	size     int64
+	modified time.Time
+}
+
+type searchModel struct {
+	previousState appState
+	dbPath        string
+	inputs        [searchFieldCount]textinput.Model
+	focus         int
+	results       []searchResult
+	more          bool // more than searchLimit files match
+	selected      int
+	err           string
+	seq           int // generation of the latest query; older results are dropped
+	searching     bool
+}
+
+// searchTickMsg fires searchDebounce after an edit; the query runs if no
+// later edit superseded it.
+type searchTickMsg struct{ seq int }
+
+type searchResultsMsg struct {
+	seq     int
+	results []searchResult
+	more    bool
+	err     error
+}
+
+func newSearchModel(dbPath string, previous appState) searchModel {
+	s := searchModel{previousState: previous, dbPath: dbPath}
+	for i, prompt := range []string{"Name contains: ", "Extensions: ", "Size: ", "Modified: "} {
+		in := textinput.New()
+		in.Prompt = prompt
+		s.inputs[i] = in
+	}
+	s.inputs[searchExt].Placeholder = ".pdf,.docx"
+	s.inputs[searchSize].Placeholder = ">10MB"
+	s.inputs[searchModified].Placeholder = "older than 3y"
+	s.inputs[searchName].Focus()
+	return s
+}
+
+func (s searchModel) query() searchQuery {
+	return searchQuery{
+		name:     s.inputs[searchName].Value(),
+		ext:      s.inputs[searchExt].Value(),
+		size:     s.inputs[searchSize].Value(),
+		modified: s.inputs[searchModified].Value(),
+	}
+}
+
+// searchSQL builds the query for q, reusing the scan's extension, size and
+// date filter syntax.
+func searchSQL(q searchQuery, now time.Time) (string, []any, error) {
+	filter, err := parseFileFilter(q.size, q.modified, now)
+	if err != nil {
+		return "", nil, err
+	}
+	where := []string{"deleted_at IS NULL"}
+	var args []any
+	if name := strings.TrimSpace(q.name); name != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(name)
+		where = append(where, `name LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escaped+"%")
+	}
+	if exts := parseExtSet(strings.TrimSpace(q.ext)); len(exts) > 0 {
+		marks := make([]string, 0, len(exts))
+		for e := range exts {
+			marks = append(marks, "?")
+			args = append(args, e)
+		}
+		where = append(where, "ext IN ("+strings.Join(marks, ", ")+")")
+	}
+	if filter.sizeAtLeast > 0 {
+		where = append(where, "size >= ?")
+		args = append(args, filter.sizeAtLeast)
+	}
+	if filter.sizeBelow > 0 {
+		where = append(where, "size < ?")
+		args = append(args, filter.sizeBelow)
+	}
+	// mtime_utc is RFC3339 in UTC, so text order is time order
+	if !filter.modifiedSince.IsZero() {
+		where = append(where, "mtime_utc >= ?")
+		args = append(args, filter.modifiedSince.UTC().Format(time.RFC3339))
+	}
+	if !filter.modifiedBefore.IsZero() {
+		where = append(where, "mtime_utc < ?")
+		args = append(args, filter.modifiedBefore.UTC().Format(time.RFC3339))
+	}
+	return `SELECT abs_path, name, COALESCE(ext, ''), COALESCE(size, 0), mtime_utc FROM files
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY name COLLATE NOCASE, abs_path LIMIT ?`, append(args, searchLimit+1), nil
+}
+
+// searchCatalog runs q against the catalog at dbPath and returns up to
+// searchLimit matches, and whether there were more.
+func searchCatalog(dbPath string, q searchQuery, now time.Time) ([]searchResult, bool, error) {
+	query, args, err := searchSQL(q, now)
+	if err != nil {
+		return nil, false, err
+	}
+	db, err := openCatalogReadOnly(dbPath)
+	if err != nil {
+		return nil, false, err
+	}
+	defer db.Close()
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	var out []searchResult
+	for rows.Next() {
+		var r searchResult
+		var mtime sql.NullString
+		if err := rows.Scan(&r.path, &r.name, &r.ext, &r.size, &mtime); err != nil {
+			return nil, false, err
+		}
+		r.modified, _ = time.Parse(time.RFC3339, mtime.String)
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	if len(out) > searchLimit {
+		return out[:searchLimit], true, nil
+	}
+	return out, false, nil
+}
+
+// scheduleSearch starts a new query generation and waits out the debounce.
+func (m *model) scheduleSearch() tea.Cmd {
+	m.search.seq++
+	seq := m.search.seq
+	return tea.Tick(searchDebounce, func(time.Time) tea.Msg { return searchTickMsg{seq: seq} })
+}
+
+func (m model) updateSearch(msg tea.Msg) (tea.Model, tea.Cmd) {
+	s := &m.search
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.windowSize = msg
+		return m, nil
+	case searchTickMsg:
+		if msg.seq != s.seq {
+			return m, nil
+		}
+		s.searching = true
+		dbPath, q, seq := s.dbPath, s.query(), s.seq
+		return m, func() tea.Msg {
+			results, more, err := searchCatalog(dbPath, q, time.Now())
+			return searchResultsMsg{seq: seq, results: results, more: more, err: err}
+		}
+	case searchResultsMsg:
+		if msg.seq != s.seq {
+			return m, nil
+		}
+		s.searching = false
+		if msg.err != nil {
+			s.err = msg.err.Error()
+			return m, nil
+		}
+		s.err = ""
+		s.results, s.more = msg.results, msg.more
+		s.selected = 0
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc":
+			m.state = s.previousState
+			return m, nil
+		case "ctrl+c":
+			return m, tea.Quit
+		case "F1":
+			m.help.previousState = m.state
+			m.state = stateHelp
+			return m, nil
+		case "tab", "shift+tab":
+			s.inputs[s.focus].Blur()
+			if msg.String() == "tab" {
+				s.focus = (s.focus + 1) % searchFieldCount
+			} else {
+				s.focus = (s.focus + searchFieldCount - 1) % searchFieldCount
+			}
+			s.inputs[s.focus].Focus()
+			return m, nil
+		case "up", "ctrl+p":
+			if s.selected > 0 {
+				s.selected--
+			}
+			return m, nil
+		case "down", "ctrl+n":
+			if s.selected < len(s.results)-1 {
+				s.selected++
+			}
+			return m, nil
+		case "pgup":
+			s.selected = max(s.selected-m.getBrowserDisplayLines(), 0)
+			return m, nil
+		case "pgdown":
+			s.selected = max(min(s.selected+m.getBrowserDisplayLines(), len(s.results)-1), 0)
+			return m, nil
+		}
+	}
+
+	// Everything else edits the focused field
+	before := s.inputs[s.focus].Value()
+	var cmd tea.Cmd
+	s.inputs[s.focus], cmd = s.inputs[s.focus].Update(msg)
+	if s.inputs[s.focus].Value() != before {
+		return m, tea.Batch(cmd, m.scheduleSearch())
+	}
+	return m, cmd
+}
+
+func (m model) viewSearch() string {
+	var b strings.Builder
+	s := m.search
+
+	fmt.Fprintf(&b, "%s\n", lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#7c3aed")).Render("🔍 Search Catalog"))
+	fmt.Fprintf(&b, "%s\n\n", lbl.Render(s.dbPath))
+	for i := range s.inputs {
+		fmt.Fprintf(&b, "%s\n", s.inputs[i].View())
+	}
+	fmt.Fprintln(&b)
+
+	switch {
+	case s.err != "":
+		fmt.Fprintf(&b, "%s %s\n", bad.Render("⚠"), s.err)
+	case s.searching && s.results == nil:
+		fmt.Fprintf(&b, "%s\n", lbl.Render("Searching..."))
+	case s.results == nil:
+		fmt.Fprintf(&b, "%s\n", lbl.Render("Start typing to search"))
+	case len(s.results) == 0:
+		fmt.Fprintf(&b, "%s\n", lbl.Render("No matching files"))
+	default:
+		maxDisplay := m.getBrowserDisplayLines()
+		start := 0
+		if s.selected >= maxDisplay {
+			start = s.selected - maxDisplay + 1
+		}
+		end := min(start+maxDisplay, len(s.results))
+
+		nameWidth := max(m.getTableWidth()-50, 16)
+		var rows [][]string
+		for i := start; i < end; i++ {
+			r := s.results[i]
+			modified := ""
+			if !r.modified.IsZero() {
+				modified = r.modified.Local().Format("2006-01-02")
+			}
+			row := []string{" ", m.wrapText(r.name, nameWidth), r.ext, formatSize(r.size), modified, m.wrapText(filepath.Base(filepath.Dir(r.path)), 20)}
+			if i == s.selected {
+				row[0] = "▸"
+				for j := range row {
+					row[j] = acc.Render(row[j])
+				}
+			}
+			rows = append(rows, row)
+		}
+		fmt.Fprintf(&b, "%s\n\n", renderTable([]string{" ", "Name", "Ext", "Size", "Modified", "Folder"}, rows))
+
+		count := fmt.Sprintf("%d files", len(s.results))
+		if s.more {
+			count = fmt.Sprintf("first %d files; narrow the search to see more", len(s.results))
+		}
+		fmt.Fprintf(&b, "%s\n", lbl.Render(fmt.Sprintf("(%d-%d of %s)", start+1, end, count)))
+		fmt.Fprintf(&b, "%s %s\n", val.Render("Path:"), acc.Render(s.results[s.selected].path))
+	}
+
+	fmt.Fprintf(&b, "\n%s\n", lbl.Render("Type to search • Tab next field • ↑/↓ select • PgUp/PgDn page • F1 help • ESC back"))
+	return b.String()
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ---------- content sniffing ----------
+
+// sniffLen is how much of a file's start sniffMIME looks at, the same
+// amount http.DetectContentType considers.
+const sniffLen = 512
+
+// Types sniffMIME reports beyond those of http.DetectContentType.
+const (
+	mimeEmpty = "inode/x-empty"
+	// mimeOLE is the compound file format of legacy Office documents and
+	// Outlook .msg files
+	mimeOLE = "application/x-ole-storage"
+)
+
+// oleMagic starts every OLE compound file.
+var oleMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// sniffMIME returns the MIME type of content from its first bytes, without
+// parameters such as charset.
+func sniffMIME(head []byte) string {
+	if len(head) == 0 {
+		return mimeEmpty
+	}
+	if bytes.HasPrefix(head, oleMagic) {
+		return mimeOLE
+	}
+	mt, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	return mt
+}
+
+// sniffFile reads the start of path and returns its sniffed MIME type.
+func sniffFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	return sniffMIME(head[:n]), nil
+}
+
+// expectedContent lists, for extensions whose content can be recognised
+// reliably, the sniffed types that agree with the extension. Other
+// extensions are never reported as mismatches.
+var expectedContent = map[string][]string{
+	".pdf":  {"application/pdf"},
+	".png":  {"image/png"},
+	".jpg":  {"image/jpeg"},
+	".jpeg": {"image/jpeg"},
+	".gif":  {"image/gif"},
+	".bmp":  {"image/bmp"},
+	".webp": {"image/webp"},
+	".gz":   {"application/x-gzip"},
+	".rar":  {"application/x-rar-compressed"},
+	".txt":  {"text/plain", "text/html", "text/xml"},
+	".csv":  {"text/plain", "text/html", "text/xml"},
+	".json": {"text/plain"},
+}
+
+func init() {
+	// Office Open XML files are ZIPs, except that password- or
+	// IRM-protected ones are OLE compound files wrapping the encrypted ZIP
+	for _, ext := range []string{".docx", ".docm", ".dotx", ".xlsx", ".xlsm", ".xltx", ".pptx", ".pptm", ".potx", ".ppsx", ".vsdx"} {
+		expectedContent[ext] = []string{"application/zip", mimeOLE}
+	}
+	// OpenDocument files and plain archives are ZIPs even when encrypted
+	for _, ext := range []string{".odt", ".ods", ".odp", ".zip"} {
+		expectedContent[ext] = []string{"application/zip"}
+	}
+	// Legacy Office formats and Outlook messages are OLE compound files
+	for _, ext := range []string{".doc", ".dot", ".xls", ".xlt", ".ppt", ".pot", ".pps", ".vsd", ".pub", ".msg"} {
+		expectedContent[ext] = []string{mimeOLE}
+	}
+}
+
+// contentMismatch reports whether a file's sniffed type contradicts its
+// extension, such as a .pdf that is really HTML or a .docx that is not a
+// ZIP. Empty files have no content to contradict anything.
+func contentMismatch(ext, sniffed string) bool {
+	want, ok := expectedContent[strings.ToLower(ext)]
+	if !ok || sniffed == "" || sniffed == mimeEmpty {
+		return false
+	}
+	for _, mt := range want {
+		if mt == sniffed {
+			return false
+		}
+	}
+	return true
+}
+
+// expectedContentText describes what an extension's content should be.
+func expectedContentText(ext string) string {
+	return strings.Join(expectedContent[strings.ToLower(ext)], " or ")
+}
+
+// ---------- mismatch report ----------
+
+// contentMismatchFile is a cataloged file whose content contradicts its
+// extension.
+type contentMismatchFile struct {
+	Path     string `json:"path"`
+	Ext      string `json:"ext"`
+	Size     int64  `json:"size"`
+	Expected string `json:"expected"` // what the extension calls for
+	Sniffed  string `json:"sniffed_mime"`
+}
+
+// mismatchReport is the outcome of findMismatches.
+type mismatchReport struct {
+	Sniffed int                   `json:"sniffed"` // live files with a sniffed type
+	Files   []contentMismatchFile `json:"files"`   // by path
+}
+
+// findMismatchesIn opens the catalog at dbPath read-only and lists its
+// content mismatches.
+func findMismatchesIn(dbPath string) (*mismatchReport, error) {
+	db, err := openCatalogReadOnly(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return findMismatches(db)
+}
+
+// findMismatches checks every live file that a scan with sniffing read.
+func findMismatches(db *sql.DB) (*mismatchReport, error) {
+	rows, err := db.Query(`
+		SELECT abs_path, COALESCE(ext, ''), COALESCE(size, 0), sniffed_mime
+		FROM files
+		WHERE sniffed_mime IS NOT NULL AND deleted_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	r := &mismatchReport{}
+	for rows.Next() {
+		var f contentMismatchFile
+		if err := rows.Scan(&f.Path, &f.Ext, &f.Size, &f.Sniffed); err != nil {
+			return nil, err
+		}
+		r.Sniffed++
+		if contentMismatch(f.Ext, f.Sniffed) {
+			f.Expected = expectedContentText(f.Ext)
+			r.Files = append(r.Files, f)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(r.Files, func(i, j int) bool { return r.Files[i].Path < r.Files[j].Path })
+	return r, nil
+}
+
+// writeMismatchesCSV writes one row per mismatched file.
+func writeMismatchesCSV(w io.Writer, r *mismatchReport) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"path", "ext", "size", "expected", "sniffed_mime"})
+	for _, f := range r.Files {
+		cw.Write([]string{f.Path, f.Ext, strconv.FormatInt(f.Size, 10), f.Expected, f.Sniffed})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeMismatchesJSON writes the report as one JSON document.
+func writeMismatchesJSON(w io.Writer, r *mismatchReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	out := *r
+	if out.Files == nil {
+		out.Files = []contentMismatchFile{}
+	}
+	return enc.Encode(out)
+}
+
+// writeMismatchesText prints a summary and the first top files.
+func writeMismatchesText(w io.Writer, r *mismatchReport, top int) {
+	fmt.Fprintf(w, "files sniffed: %d, content mismatches: %d\n", r.Sniffed, len(r.Files))
+	if r.Sniffed == 0 {
+		fmt.Fprintln(w, "no file has a sniffed type yet; scan with --sniff first")
+		return
+	}
+	for i, f := range r.Files {
+		if i == top {
+			fmt.Fprintf(w, "... and %d more\n", len(r.Files)-top)
+			break
+		}
+		fmt.Fprintf(w, "%s\n  is %s, expected %s\n", f.Path, f.Sniffed, f.Expected)
+	}
+}
@@ -267,7 +267,7 @@ func renderTable(headers []string, rows [][]string) string {
 		style := headingStyle.Copy().Width(colWidths[i]).Align(lipgloss.Left)
 		headerCells = append(headerCells, style.Render(header))
 	}
-	headerRow := lipgloss.JoinHorizontal(lipgloss.Left, headerCells...)
+	headerRow := strings.Join(headerCells, " │ ")
 
 	// Render separator
 	var sepCells []string
@@ -289,7 +289,7 @@ func renderTable(headers []string, rows [][]string) string {
 				cells = append(cells, style.Render(cell))
 			}
 		}
-		renderedRows = append(renderedRows, lipgloss.JoinHorizontal(lipgloss.Left, cells...))
+		renderedRows = append(renderedRows, strings.Join(cells, " │ "))
 	}
 
 	// Combine all parts
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------- integrity verification ----------
+
+// Values of verify_results.status.
+const (
+	verifyOK        = "ok"
+	verifyModified  = "modified"  // size or mtime changed since the scan, so the hash is not comparable
+	verifyCorrupted = "corrupted" // same size and mtime as cataloged, different content
+	verifyMissing   = "missing"
+	verifyError     = "error" // the file exists but could not be read
+)
+
+// verifyOptions controls verifyCatalog.
+type verifyOptions struct {
+	workers    int
+	onProgress func(done, total int) // called as files are checked, if set
+}
+
+// verifyResult is one row of verify_results.
+type verifyResult struct {
+	Path     string `json:"path"`
+	Status   string `json:"status"`
+	Expected string `json:"expected_sha256"`
+	Actual   string `json:"actual_sha256,omitempty"`
+	// Size and Modified are what was found on disk
+	Size     int64  `json:"size,omitempty"`
+	Modified string `json:"mtime_utc,omitempty"`
+	Error    string `json:"error,omitempty"`
+
+	catalogSize  int64
+	catalogMtime string
+}
+
+// verifySummary is the outcome of one verify run.
+type verifySummary struct {
+	RunID     string         `json:"verify_run"`
+	Total     int            `json:"total"` // files with a stored hash
+	Checked   int            `json:"checked"`
+	OK        int            `json:"ok"`
+	Modified  int            `json:"modified"`
+	Corrupted int            `json:"corrupted"`
+	Missing   int            `json:"missing"`
+	Errors    int            `json:"errors"`
+	Cancelled bool           `json:"cancelled"`
+	Problems  []verifyResult `json:"problems"` // every result that is not ok, by path
+}
+
+// clean reports whether every file was checked and matched.
+func (s *verifySummary) clean() bool {
+	return !s.Cancelled && s.OK == s.Total
+}
+
+// verifyCatalog re-hashes every cataloged file that has a stored sha256 and
+// records how each compares in verify_results. When ctx is cancelled the
+// files checked so far are still recorded and the summary is marked
+// cancelled.
+func verifyCatalog(ctx context.Context, dbPath string, opts verifyOptions) (*verifySummary, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("no catalog at %s: %w", dbPath, err)
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	if err := initSchema(db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT abs_path, COALESCE(size, 0), COALESCE(mtime_utc, ''), sha256 FROM files
+		WHERE deleted_at IS NULL AND sha256 IS NOT NULL AND sha256 != ''
+		ORDER BY abs_path
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var files []verifyResult
+	for rows.Next() {
+		var r verifyResult
+		if err := rows.Scan(&r.Path, &r.catalogSize, &r.catalogMtime, &r.Expected); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		files = append(files, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	s := &verifySummary{RunID: newRunID(), Total: len(files)}
+	workers := opts.workers
+	if workers < 1 {
+		workers = defaultHashWorkers()
+	}
+	jobs := make(chan int)
+	checked := make([]bool, len(files))
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				verifyFile(&files[i])
+				mu.Lock()
+				checked[i] = true
+				done++
+				if opts.onProgress != nil {
+					opts.onProgress(done, len(files))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for i := range files {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			s.Cancelled = true
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO verify_results
+		(verify_run, abs_path, status, expected_sha256, actual_sha256, size, mtime_utc, error, checked_utc)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	defer stmt.Close()
+	now := time.Now().UTC().Format(time.RFC3339)
+	for i, r := range files {
+		if !checked[i] {
+			continue
+		}
+		if _, err := stmt.Exec(s.RunID, r.Path, r.Status, r.Expected, nullIfEmpty(r.Actual),
+			r.Size, nullIfEmpty(r.Modified), nullIfEmpty(r.Error), now); err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+		s.Checked++
+		switch r.Status {
+		case verifyOK:
+			s.OK++
+			continue
+		case verifyModified:
+			s.Modified++
+		case verifyCorrupted:
+			s.Corrupted++
+		case verifyMissing:
+			s.Missing++
+		default:
+			s.Errors++
+		}
+		s.Problems = append(s.Problems, r)
+	}
+	return s, tx.Commit()
+}
+
+// verifyFile checks r against the file on disk and sets its status. A file
+// whose size or mtime changed is reported as modified without reading it:
+// its stored hash describes an older version.
+func verifyFile(r *verifyResult) {
+	info, err := os.Stat(r.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		r.Status = verifyMissing
+		return
+	}
+	if err != nil {
+		r.Status, r.Error = verifyError, err.Error()
+		return
+	}
+	r.Size, r.Modified = info.Size(), info.ModTime().UTC().Format(time.RFC3339)
+	if !info.Mode().IsRegular() {
+		r.Status, r.Error = verifyModified, "no longer a regular file"
+		return
+	}
+	if r.Size != r.catalogSize || r.Modified != r.catalogMtime {
+		r.Status = verifyModified
+		return
+	}
+	r.Actual, err = hashFile(r.Path)
+	switch {
+	case err != nil:
+		r.Status, r.Error = verifyError, err.Error()
+	case r.Actual != r.Expected:
+		r.Status = verifyCorrupted
+	default:
+		r.Status = verifyOK
+	}
+}
+
+// nullIfEmpty stores empty strings as NULL.
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// writeVerifyText prints the counts and every file that did not match.
+func writeVerifyText(w io.Writer, s *verifySummary) {
+	fmt.Fprintf(w, "verify run: %s\n", s.RunID)
+	fmt.Fprintf(w, "checked:    %d of %d files with a stored hash\n", s.Checked, s.Total)
+	fmt.Fprintf(w, "ok:         %d\n", s.OK)
+	fmt.Fprintf(w, "modified:   %d\n", s.Modified)
+	fmt.Fprintf(w, "corrupted:  %d\n", s.Corrupted)
+	fmt.Fprintf(w, "missing:    %d\n", s.Missing)
+	fmt.Fprintf(w, "errors:     %d\n", s.Errors)
+	if s.Cancelled {
+		fmt.Fprintln(w, "interrupted before every file was checked")
+	}
+	for _, r := range s.Problems {
+		line := fmt.Sprintf("%-9s  %s", r.Status, r.Path)
+		if r.Error != "" {
+			line += " (" + r.Error + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// writeVerifyJSON writes the summary as one JSON document.
+func writeVerifyJSON(w io.Writer, s *verifySummary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	out := *s
+	if out.Problems == nil {
+		out.Problems = []verifyResult{}
+	}
+	return enc.Encode(out)
+}
+
+// ---------- verify screen ----------
+
+type verifyModel struct {
+	summary  *verifySummary
+	cancel   context.CancelFunc
+	done     int // progress while checking
+	total    int
+	selected int
+	err      string
+}
+
+type verifyMsg struct {
+	summary *verifySummary
+	err     error
+}
+
+type verifyProgressMsg struct{ done, total int }
+
+// startVerify runs a verify in the background, reporting progress through
+// send.
+func startVerify(ctx context.Context, dbPath string, send func(tea.Msg)) tea.Cmd {
+	return func() tea.Msg {
+		s, err := verifyCatalog(ctx, dbPath, verifyOptions{
+			onProgress: func(done, total int) {
+				if done == total || done%25 == 0 {
+					send(verifyProgressMsg{done: done, total: total})
+				}
+			},
+		})
+		return verifyMsg{summary: s, err: err}
+	}
+}
+
+func (m model) updateVerify(msg tea.Msg) (tea.Model, tea.Cmd) {
+	v := &m.verify
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.windowSize = msg
+		return m, nil
+	case verifyProgressMsg:
+		v.done, v.total = msg.done, msg.total
+		return m, nil
+	case verifyMsg:
+		v.cancel = nil
+		if msg.err != nil {
+			v.err = msg.err.Error()
+			return m, nil
+		}
+		v.summary, v.selected = msg.summary, 0
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc":
+			// Stopping early still records the files already checked
+			if v.cancel != nil {
+				v.cancel()
+				return m, nil
+			}
+			m.state = stateDone
+			return m, nil
+		case "?", "h", "F1":
+			m.help.previousState = m.state
+			m.state = stateHelp
+			return m, nil
+		case "up", "k":
+			if v.selected > 0 {
+				v.selected--
+			}
+		case "down", "j":
+			if v.summary != nil && v.selected < len(v.summary.Problems)-1 {
+				v.selected++
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m model) viewVerify() string {
+	var b strings.Builder
+	v := m.verify
+
+	fmt.Fprintf(&b, "%s\n\n",
+		lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#7c3aed")).Render("🛡  Verify Integrity"))
+
+	if v.err != "" {
+		errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ef4444")).Bold(true)
+		fmt.Fprintf(&b, "%s %s\n\n", errorStyle.Render("⚠ Error:"), v.err)
+		fmt.Fprintf(&b, "%s\n", lbl.Render("Press ESC to go back"))
+		return b.String()
+	}
+	if v.summary == nil {
+		if v.total > 0 {
+			fmt.Fprintf(&b, "%s\n", lbl.Render(fmt.Sprintf("Re-hashing files... %d of %d", v.done, v.total)))
+		} else {
+			fmt.Fprintf(&b, "%s\n", lbl.Render("Reading the catalog..."))
+		}
+		fmt.Fprintf(&b, "\n%s\n", lbl.Render("ESC to stop (files checked so far are recorded)"))
+		return b.String()
+	}
+
+	s := v.summary
+	count := func(label string, n int, style lipgloss.Style) {
+		if n == 0 {
+			style = lbl
+		}
+		fmt.Fprintf(&b, "%s %s\n", val.Render(fmt.Sprintf("%-10s", label)), style.Render(formatCount(int64(n))))
+	}
+	count("OK:", s.OK, ok)
+	count("Modified:", s.Modified, acc)
+	count("Corrupted:", s.Corrupted, bad)
+	count("Missing:", s.Missing, bad)
+	count("Errors:", s.Errors, bad)
+	fmt.Fprintf(&b, "%s\n", lbl.Render(fmt.Sprintf("Checked %d of %d files with a stored hash • recorded as run %s", s.Checked, s.Total, s.RunID)))
+	if s.Cancelled {
+		fmt.Fprintf(&b, "%s\n", bad.Render("Stopped before every file was checked"))
+	}
+	fmt.Fprintln(&b)
+
+	if len(s.Problems) == 0 {
+		if s.Total == 0 {
+			fmt.Fprintf(&b, "%s\n", lbl.Render("No files have a stored hash; scan with hashing on to create a baseline"))
+		} else if s.clean() {
+			fmt.Fprintf(&b, "%s\n", ok.Render("Every file matches its stored hash"))
+		}
+	} else {
+		maxDisplay := m.getBrowserDisplayLines()
+		start := 0
+		if v.selected >= maxDisplay {
+			start = v.selected - maxDisplay + 1
+		}
+		end := min(start+maxDisplay, len(s.Problems))
+		pathWidth := max(m.getTableWidth()-16, 16)
+		var rows [][]string
+		for i := start; i < end; i++ {
+			r := s.Problems[i]
+			row := []string{" ", r.Status, m.wrapText(r.Path, pathWidth)}
+			if i == v.selected {
+				row[0] = "▸"
+				for j := range row {
+					row[j] = acc.Render(row[j])
+				}
+			}
+			rows = append(rows, row)
+		}
+		fmt.Fprintf(&b, "%s\n", renderTable([]string{" ", "Status", "Path"}, rows))
+		if len(s.Problems) > maxDisplay {
+			fmt.Fprintf(&b, "\n%s\n", lbl.Render(fmt.Sprintf("(%d-%d of %d)", start+1, end, len(s.Problems))))
+		}
+		r := s.Problems[v.selected]
+		switch {
+		case r.Error != "":
+			fmt.Fprintf(&b, "\n%s %s\n", val.Render("Error:"), r.Error)
+		case r.Status == verifyCorrupted:
+			fmt.Fprintf(&b, "\n%s %s\n%s %s\n", val.Render("Expected:"), lbl.Render(r.Expected), val.Render("Actual:  "), bad.Render(r.Actual))
+		case r.Status == verifyModified:
+			fmt.Fprintf(&b, "\n%s %s, %s (cataloged %s, %s)\n", val.Render("Now:"),
+				formatSize(r.Size), r.Modified, formatSize(r.catalogSize), r.catalogMtime)
+		}
+	}
+
+	fmt.Fprintf(&b, "\n%s\n", lbl.Render("↑/↓ or j/k select • F1 help • ESC to go back"))
+	return b.String()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// maxDirReaders bounds how many directory listings are read ahead of the
+// walk. On network shares each ReadDir costs tens of milliseconds, so
+// overlapping them matters far more than CPU.
+const maxDirReaders = 8
+
+// dirReadQueue is how many read-ahead requests may wait for a reader. When
+// it is full the walker simply reads the directory itself when it gets there.
+const dirReadQueue = 1024
+
+// dirRead is one directory listing, read either by a reader goroutine ahead
+// of time or by the walker when it reaches the directory first.
+type dirRead struct {
+	path    string
+	claimed atomic.Bool
+	done    chan struct{}
+	entries []fs.DirEntry
+	err     error
+}
+
+func newDirRead(path string) *dirRead {
+	return &dirRead{path: path, done: make(chan struct{})}
+}
+
+// run reads the directory unless someone else already has.
+func (r *dirRead) run() {
+	if !r.claimed.CompareAndSwap(false, true) {
+		return
+	}
+	r.entries, r.err = os.ReadDir(r.path)
+	close(r.done)
+}
+
+func (r *dirRead) wait() ([]fs.DirEntry, error) {
+	r.run()
+	<-r.done
+	return r.entries, r.err
+}
+
+// parallelWalkDir is filepath.WalkDir with directory listings read ahead by
+// up to readers goroutines. fn is called from a single goroutine in exactly
+// the order, and with exactly the errors, that filepath.WalkDir would use,
+// so checkpoints and row counts do not depend on read timing.
+//
+// prune reports directories fn is going to skip, so they are not read
+// ahead. It is called on the walking goroutine after fn has seen the
+// directory's parent.
+//
+// Links are never descended unless follow returns the entry to walk in
+// their place; nil leaves the link a leaf, as filepath.WalkDir does.
+func parallelWalkDir(ctx context.Context, root string, readers int, prune func(path string, d fs.DirEntry) bool, follow func(path string, d fs.DirEntry) fs.DirEntry, fn fs.WalkDirFunc) error {
+	if readers < 1 {
+		readers = 1
+	}
+	queue := make(chan *dirRead, dirReadQueue)
+	var wg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range queue {
+				if ctx.Err() == nil {
+					r.run()
+				}
+			}
+		}()
+	}
+	defer func() {
+		close(queue)
+		wg.Wait()
+	}()
+
+	w := &parallelWalker{queue: queue, prune: prune, follow: follow, fn: fn}
+	var err error
+	if info, lerr := os.Lstat(root); lerr != nil {
+		err = fn(root, nil, lerr)
+	} else {
+		err = w.walk(root, fs.FileInfoToDirEntry(info), nil)
+	}
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+type parallelWalker struct {
+	queue  chan<- *dirRead
+	prune  func(path string, d fs.DirEntry) bool
+	follow func(path string, d fs.DirEntry) fs.DirEntry
+	fn     fs.WalkDirFunc
+}
+
+// walk mirrors filepath's walkDir; ahead is the directory's read-ahead
+// request, if one was queued.
+func (w *parallelWalker) walk(path string, d fs.DirEntry, ahead *dirRead) error {
+	if isLink(d.Type()) && w.follow != nil {
+		if target := w.follow(path, d); target != nil {
+			d = target
+		}
+	}
+	if err := w.fn(path, d, nil); err != nil || !d.IsDir() || isLink(d.Type()) {
+		if err == fs.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	if ahead == nil {
+		ahead = newDirRead(path)
+	}
+	entries, err := ahead.wait()
+	if err != nil {
+		// Second call, to report the ReadDir error
+		if err = w.fn(path, d, err); err != nil {
+			if err == fs.SkipDir && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	// Queue the subdirectories in walk order, so readers tend to finish
+	// them just before the walk needs them
+	reads := make([]*dirRead, len(entries))
+	for i, e := range entries {
+		if !e.IsDir() || isLink(e.Type()) {
+			continue
+		}
+		p := filepath.Join(path, e.Name())
+		if w.prune != nil && w.prune(p, e) {
+			continue
+		}
+		r := newDirRead(p)
+		select {
+		case w.queue <- r:
+			reads[i] = r
+		default:
+		}
+	}
+
+	for i, e := range entries {
+		if err := w.walk(filepath.Join(path, e.Name()), e, reads[i]); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
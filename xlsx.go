@@ -0,0 +1,235 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- minimal XLSX writer ----------
+
+// xlsxMaxRows is Excel's row limit per sheet. Longer tables continue on
+// sheets named "Files (2)" and so on, each with its own header row.
+var xlsxMaxRows = 1 << 20
+
+// Cell styles, indexes into cellXfs in xlsxStyles.
+const (
+	xlsxStyleDefault = 0
+	xlsxStyleHeader  = 1
+	xlsxStyleNumber  = 2
+	xlsxStyleDate    = 3
+)
+
+// xlsxEpoch is day zero of Excel's 1900 date system, adjusted for its
+// phantom 1900-02-29.
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxWriter streams a workbook into a zip archive one sheet at a time.
+// Strings are written inline rather than through a shared string table,
+// so rows never have to be held in memory.
+type xlsxWriter struct {
+	zw     *zip.Writer
+	sheets []string
+
+	// Current sheet
+	bw       *bufio.Writer
+	name     string
+	header   []string
+	widths   []float64
+	rows     int
+	part     int
+	rowCells []byte
+}
+
+func newXLSXWriter(w io.Writer) *xlsxWriter {
+	return &xlsxWriter{zw: zip.NewWriter(w)}
+}
+
+// startTable begins a sheet whose first row is header. widths sets column
+// widths in characters; a zero width leaves Excel's default.
+func (x *xlsxWriter) startTable(name string, header []string, widths []float64) error {
+	x.name, x.header, x.widths, x.part = name, header, widths, 1
+	return x.startSheet(name)
+}
+
+func (x *xlsxWriter) startSheet(name string) error {
+	x.sheets = append(x.sheets, name)
+	f, err := x.zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(x.sheets)))
+	if err != nil {
+		return err
+	}
+	x.bw = bufio.NewWriter(f)
+	x.rows = 0
+	x.bw.WriteString(xml.Header)
+	x.bw.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	// Keep the header row visible while scrolling
+	x.bw.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	if len(x.widths) > 0 {
+		x.bw.WriteString("<cols>")
+		for i, w := range x.widths {
+			if w > 0 {
+				fmt.Fprintf(x.bw, `<col min="%d" max="%d" width="%g" customWidth="1"/>`, i+1, i+1, w)
+			}
+		}
+		x.bw.WriteString("</cols>")
+	}
+	x.bw.WriteString("<sheetData>")
+	values := make([]any, len(x.header))
+	for i, h := range x.header {
+		values[i] = h
+	}
+	return x.writeRow(values, xlsxStyleHeader)
+}
+
+// row appends a data row. Values may be string, int64, float64, time.Time
+// or nil for an empty cell; a zero time is left empty too.
+func (x *xlsxWriter) row(values ...any) error {
+	if x.rows >= xlsxMaxRows {
+		if err := x.endSheet(); err != nil {
+			return err
+		}
+		x.part++
+		if err := x.startSheet(fmt.Sprintf("%s (%d)", x.name, x.part)); err != nil {
+			return err
+		}
+	}
+	return x.writeRow(values, xlsxStyleDefault)
+}
+
+func (x *xlsxWriter) writeRow(values []any, style int) error {
+	x.rows++
+	b := x.rowCells[:0]
+	b = append(b, `<row r="`...)
+	b = strconv.AppendInt(b, int64(x.rows), 10)
+	b = append(b, `">`...)
+	for i, v := range values {
+		ref := xlsxColumn(i) + strconv.Itoa(x.rows)
+		switch v := v.(type) {
+		case nil:
+			continue
+		case string:
+			if v == "" {
+				continue
+			}
+			b = append(b, `<c r="`+ref+`" t="inlineStr"`...)
+			if style != xlsxStyleDefault {
+				b = append(b, ` s="`+strconv.Itoa(style)+`"`...)
+			}
+			b = append(b, `><is><t xml:space="preserve">`...)
+			b = appendXMLText(b, v)
+			b = append(b, `</t></is></c>`...)
+		case int64:
+			b = append(b, `<c r="`+ref+`" s="`+strconv.Itoa(xlsxStyleNumber)+`"><v>`...)
+			b = strconv.AppendInt(b, v, 10)
+			b = append(b, `</v></c>`...)
+		case float64:
+			b = append(b, `<c r="`+ref+`"><v>`...)
+			b = strconv.AppendFloat(b, v, 'g', -1, 64)
+			b = append(b, `</v></c>`...)
+		case time.Time:
+			if v.IsZero() {
+				continue
+			}
+			b = append(b, `<c r="`+ref+`" s="`+strconv.Itoa(xlsxStyleDate)+`"><v>`...)
+			b = strconv.AppendFloat(b, xlsxSerial(v), 'f', -1, 64)
+			b = append(b, `</v></c>`...)
+		default:
+			return fmt.Errorf("xlsx: unsupported cell value %T", v)
+		}
+	}
+	b = append(b, `</row>`...)
+	x.rowCells = b
+	_, err := x.bw.Write(b)
+	return err
+}
+
+// endSheet closes the current sheet, with an auto filter over the table.
+func (x *xlsxWriter) endSheet() error {
+	x.bw.WriteString("</sheetData>")
+	if len(x.header) > 0 {
+		fmt.Fprintf(x.bw, `<autoFilter ref="A1:%s%d"/>`, xlsxColumn(len(x.header)-1), x.rows)
+	}
+	x.bw.WriteString("</worksheet>")
+	return x.bw.Flush()
+}
+
+// close writes the workbook parts that list the sheets and finishes the
+// archive.
+func (x *xlsxWriter) close() error {
+	var wb, rels, types strings.Builder
+	wb.WriteString(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	types.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i, name := range x.sheets {
+		n := i + 1
+		fmt.Fprintf(&wb, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, string(appendXMLText(nil, name)), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+	}
+	wb.WriteString(`</sheets></workbook>`)
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`, len(x.sheets)+1)
+	types.WriteString(`</Types>`)
+
+	for _, part := range []struct{ name, body string }{
+		{"[Content_Types].xml", types.String()},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", wb.String()},
+		{"xl/_rels/workbook.xml.rels", rels.String()},
+		{"xl/styles.xml", xlsxStyles},
+	} {
+		f, err := x.zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return err
+		}
+	}
+	return x.zw.Close()
+}
+
+// xlsxStyles defines the cell styles: default, bold header, integer with
+// thousands separators, and date-time.
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="4">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="3" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs></styleSheet>`
+
+// xlsxColumn returns the column letters for a zero-based index: A, B, ... Z, AA.
+func xlsxColumn(i int) string {
+	var b []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		b = append([]byte{byte('A' + (i-1)%26)}, b...)
+	}
+	return string(b)
+}
+
+// xlsxSerial converts t to an Excel date serial in UTC.
+func xlsxSerial(t time.Time) float64 {
+	return float64(t.UTC().Sub(xlsxEpoch)) / float64(24*time.Hour)
+}
+
+// appendXMLText appends s escaped for XML. Characters XML cannot carry,
+// such as control characters in odd file names, become U+FFFD.
+func appendXMLText(b []byte, s string) []byte {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return append(b, sb.String()...)
+}